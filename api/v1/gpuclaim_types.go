@@ -8,6 +8,7 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 // +kubebuilder:printcolumn:name="Req",type=string,JSONPath=.spec.devices.count
 // +kubebuilder:printcolumn:name="Policy",type=string,JSONPath=.spec.devices.policy
 // +kubebuilder:printcolumn:name="Topology",type=string,JSONPath=.spec.topology.mode
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=.status.phase
 // +kubebuilder:printcolumn:name="Allocated",type=string,JSONPath=.status.allocated
 
 // GpuClaim defines a declarative GPU allocation request.
@@ -21,24 +22,29 @@ type GpuClaim struct {
 
 // GpuClaimSpec encodes the desired placement.
 type GpuClaimSpec struct {
-	Selector *NodeSelector   `json:"selector,omitempty"`
-	Devices  DeviceRequest   `json:"devices"`
-	Topology *TopologyPolicy `json:"topology,omitempty"`
-	// Optional: link to an external PodGroup (Volcano/Kueue). Keep MVP simple.
+	// Selector restricts placement to nodes whose labels match.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	Devices  DeviceRequest         `json:"devices"`
+	Topology *TopologyPolicy       `json:"topology,omitempty"`
+	// Pods whose claims share a GangRef in a namespace are admitted together.
+	// "PodGroup/<name>" and "Workload/<name>" refer to a Volcano PodGroup or
+	// Kueue Workload in the namespace: GPUs are reserved only once it is
+	// admitted, and its minMember sizes the gang.
 	GangRef string `json:"gangRef,omitempty"`
-}
-
-// NodeSelector mirrors corev1 label selector semantics (simplified for MVP).
-type NodeSelector struct {
-	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+	// GangSize is the number of pods that must hold GPUs before any of the
+	// gang binds. Falls back to the pod's gpu.scheduling/gang-size annotation.
+	// Ignored when GangRef names a PodGroup or Workload.
+	GangSize int `json:"gangSize,omitempty"`
 }
 
 // DeviceRequest describes GPU needs.
 type DeviceRequest struct {
 	Count       int    `json:"count"`
-	Policy      string `json:"policy,omitempty"`      // contiguous|spread|preferIds
+	Policy      string `json:"policy,omitempty"`      // contiguous|spread|preferIds|buddy
 	PreferIDs   []int  `json:"preferIds,omitempty"`   // optional pinned ids
 	Exclusivity string `json:"exclusivity,omitempty"` // Exclusive|Shared|MIG
+	// StrictPreferIDs makes preferIds a hard requirement instead of a preference.
+	StrictPreferIDs bool `json:"strictPreferIds,omitempty"`
 }
 
 // TopologyPolicy encodes NVLink bandwidth preferences.
@@ -47,6 +53,24 @@ type TopologyPolicy struct {
 	MinBandwidthGBps int    `json:"minBandwidthGBps,omitempty"`
 }
 
+// GpuClaim phases reported in GpuClaimStatus.Phase.
+const (
+	ClaimPending  = "Pending"
+	ClaimReserved = "Reserved"
+	ClaimBound    = "Bound"
+	ClaimFailed   = "Failed"
+)
+
+// Condition types reported in GpuClaimStatus.Conditions.
+const (
+	// ClaimConditionReserved is True while GPUs are held for a pod of the claim.
+	ClaimConditionReserved = "Reserved"
+	// ClaimConditionBound is True once a pod of the claim is bound with GPUs.
+	ClaimConditionBound = "Bound"
+	// ClaimConditionSchedulable turns False when pods stay unschedulable too long.
+	ClaimConditionSchedulable = "Schedulable"
+)
+
 // GpuClaimStatus reflects scheduler progress.
 type GpuClaimStatus struct {
 	Phase     string `json:"phase,omitempty"` // Pending|Reserved|Bound|Failed
@@ -54,6 +78,9 @@ type GpuClaimStatus struct {
 	GPUIds    []int  `json:"gpuIds,omitempty"`
 	Allocated string `json:"allocated,omitempty"` // e.g. node-a:0,1,2
 	Message   string `json:"message,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
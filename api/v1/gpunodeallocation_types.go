@@ -0,0 +1,43 @@
+package v1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=gna
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=.spec.nodeName
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=.metadata.creationTimestamp
+
+// GpuNodeAllocation is the scheduler's ledger of GPUs reserved on one node.
+// It is named after the node and written with optimistic concurrency, so a
+// multi-GPU reservation lands or fails as a single update.
+type GpuNodeAllocation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GpuNodeAllocationSpec `json:"spec,omitempty"`
+}
+
+// GpuNodeAllocationSpec lists the pods holding GPUs on the node.
+type GpuNodeAllocationSpec struct {
+	NodeName string `json:"nodeName"`
+	// +listType=map
+	// +listMapKey=uid
+	Allocations []PodAllocation `json:"allocations,omitempty"`
+}
+
+// PodAllocation records the GPUs reserved for one pod.
+type PodAllocation struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	GPUIds    []int  `json:"gpuIds"`
+}
+
+// +kubebuilder:object:root=true
+
+// GpuNodeAllocationList lists node allocation objects.
+type GpuNodeAllocationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GpuNodeAllocation `json:"items"`
+}
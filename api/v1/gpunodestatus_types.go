@@ -7,6 +7,10 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Node",type=string,JSONPath=.spec.nodeName
 // +kubebuilder:printcolumn:name="Devices",type=integer,JSONPath=.status.total
+// +kubebuilder:printcolumn:name="Product",type=string,JSONPath=.status.devices[0].product
+// +kubebuilder:printcolumn:name="Memory",type=integer,JSONPath=.status.devices[0].memoryMiB,priority=1
+// +kubebuilder:printcolumn:name="Driver",type=string,JSONPath=.status.devices[0].driverVersion,priority=1
+// +kubebuilder:printcolumn:name="CUDA",type=string,JSONPath=.status.devices[0].cudaVersion,priority=1
 
 // GpuNodeStatus is posted by the DaemonSet agent.
 type GpuNodeStatus struct {
@@ -25,16 +29,45 @@ type GpuNodeStatusSpec struct {
 // Device carries per GPU metadata.
 type Device struct {
 	ID        int      `json:"id"`
-	InUseBy   []string `json:"inUseBy,omitempty"` // pod UIDs
-	Health    string   `json:"health,omitempty"`  // Healthy|Unhealthy|Other
+	InUseBy   []string `json:"inUseBy,omitempty"` // pod UIDs; the scheduler treats listed devices as occupied
+	Health    string   `json:"health,omitempty"`  // Healthy|Unhealthy|Unknown; only Healthy (and optionally Unknown) is allocated
 	Bandwidth int      `json:"bandwidthGBps,omitempty"`
-	Island    string   `json:"island,omitempty"` // NVLink island identifier
+	Island    string   `json:"island,omitempty"`   // NVLink island identifier
+	PCIeRoot  string   `json:"pcieRoot,omitempty"` // PCIe root port or switch identifier
+	PCIBusID  string   `json:"pciBusId,omitempty"` // PCI address, domain:bus:device.function
+	NUMANode  *int     `json:"numaNode,omitempty"` // NUMA node of the PCI device; unset when the host has none
+
+	UUID              string `json:"uuid,omitempty"`
+	Product           string `json:"product,omitempty"`           // e.g. NVIDIA H100 80GB HBM3
+	MemoryMiB         int    `json:"memoryMiB,omitempty"`         // total device memory
+	ComputeCapability string `json:"computeCapability,omitempty"` // CUDA compute capability, e.g. 9.0
+	DriverVersion     string `json:"driverVersion,omitempty"`
+	CUDAVersion       string `json:"cudaVersion,omitempty"` // highest CUDA version the driver supports
+	MIG               string `json:"mig,omitempty"`         // Enabled|Disabled; unset when the GPU has no MIG support
+}
+
+// Device health values reported in Device.Health.
+const (
+	HealthHealthy   = "Healthy"
+	HealthUnhealthy = "Unhealthy"
+	HealthUnknown   = "Unknown"
+)
+
+// Link is the interconnect between two GPUs of the node.
+type Link struct {
+	A         int    `json:"a"`
+	B         int    `json:"b"`
+	Type      string `json:"type"`                    // NVSwitch|NVLink|PCIeSwitch|Socket|System
+	Bandwidth int    `json:"bandwidthGBps,omitempty"` // unset uses the type's typical bandwidth
 }
 
 // GpuNodeStatusStatus holds aggregated telemetry.
 type GpuNodeStatusStatus struct {
 	Devices []Device `json:"devices,omitempty"`
-	Total   int      `json:"total,omitempty"`
+	// Links lists each GPU pair at most once; pairs not listed are System.
+	// Without links the scheduler derives topology from islands.
+	Links []Link `json:"links,omitempty"`
+	Total int    `json:"total,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -16,6 +16,8 @@ var (
 			&GpuClaimList{},
 			&GpuNodeStatus{},
 			&GpuNodeStatusList{},
+			&GpuNodeAllocation{},
+			&GpuNodeAllocationList{},
 		)
 		metav1.AddToGroupVersion(scheme, GroupVersion)
 		return nil
@@ -8,6 +8,7 @@ Code generated by controller-gen. DO NOT EDIT.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -95,7 +96,7 @@ func (in *GpuClaimSpec) DeepCopyInto(out *GpuClaimSpec) {
 	*out = *in
 	if in.Selector != nil {
 		in, out := &in.Selector, &out.Selector
-		*out = new(NodeSelector)
+		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
 	in.Devices.DeepCopyInto(&out.Devices)
@@ -124,6 +125,13 @@ func (in *GpuClaimStatus) DeepCopyInto(out *GpuClaimStatus) {
 		*out = make([]int, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuClaimStatus.
@@ -136,6 +144,86 @@ func (in *GpuClaimStatus) DeepCopy() *GpuClaimStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GpuNodeAllocation) DeepCopyInto(out *GpuNodeAllocation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuNodeAllocation.
+func (in *GpuNodeAllocation) DeepCopy() *GpuNodeAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(GpuNodeAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GpuNodeAllocation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GpuNodeAllocationList) DeepCopyInto(out *GpuNodeAllocationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GpuNodeAllocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuNodeAllocationList.
+func (in *GpuNodeAllocationList) DeepCopy() *GpuNodeAllocationList {
+	if in == nil {
+		return nil
+	}
+	out := new(GpuNodeAllocationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GpuNodeAllocationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GpuNodeAllocationSpec) DeepCopyInto(out *GpuNodeAllocationSpec) {
+	*out = *in
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make([]PodAllocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuNodeAllocationSpec.
+func (in *GpuNodeAllocationSpec) DeepCopy() *GpuNodeAllocationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GpuNodeAllocationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GpuNodeStatus) DeepCopyInto(out *GpuNodeStatus) {
 	*out = *in
@@ -220,6 +308,11 @@ func (in *GpuNodeStatusStatus) DeepCopyInto(out *GpuNodeStatusStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Links != nil {
+		in, out := &in.Links, &out.Links
+		*out = make([]Link, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuNodeStatusStatus.
@@ -240,6 +333,11 @@ func (in *Device) DeepCopyInto(out *Device) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NUMANode != nil {
+		in, out := &in.NUMANode, &out.NUMANode
+		*out = new(int)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Device.
@@ -253,23 +351,36 @@ func (in *Device) DeepCopy() *Device {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NodeSelector) DeepCopyInto(out *NodeSelector) {
+func (in *Link) DeepCopyInto(out *Link) {
 	*out = *in
-	if in.MatchLabels != nil {
-		in, out := &in.MatchLabels, &out.MatchLabels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Link.
+func (in *Link) DeepCopy() *Link {
+	if in == nil {
+		return nil
+	}
+	out := new(Link)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodAllocation) DeepCopyInto(out *PodAllocation) {
+	*out = *in
+	if in.GPUIds != nil {
+		in, out := &in.GPUIds, &out.GPUIds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeSelector.
-func (in *NodeSelector) DeepCopy() *NodeSelector {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodAllocation.
+func (in *PodAllocation) DeepCopy() *PodAllocation {
 	if in == nil {
 		return nil
 	}
-	out := new(NodeSelector)
+	out := new(PodAllocation)
 	in.DeepCopyInto(out)
 	return out
 }
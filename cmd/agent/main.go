@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,9 +18,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/discovery"
+)
+
+var (
+	backend  = flag.String("discovery", discovery.BackendNvidiaSMI, "GPU discovery backend: nvml, nvidia-smi, sysfs or fake")
+	hostRoot = flag.String("host-root", "/", "Root of the host filesystem read by the nvml and sysfs discovery backends")
+	fakeGPUs = flag.Int("fake-gpus", 8, "Number of GPUs reported by the fake discovery backend")
 )
 
 func main() {
+	flag.Parse()
+	d, err := newDiscoverer(*backend)
+	if err != nil {
+		klog.Fatalf("%v", err)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
@@ -52,27 +67,34 @@ func main() {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			devices := discoverDevices()
-			if err := publishStatus(ctx, c, nodeName, devices); err != nil {
+			gpus, err := d.Discover(ctx)
+			if err != nil {
+				klog.ErrorS(err, "failed to discover GPUs", "backend", *backend)
+				continue
+			}
+			if err := publishStatus(ctx, c, nodeName, discovery.Devices(gpus), discovery.Links(gpus)); err != nil {
 				klog.ErrorS(err, "failed to publish GPU status")
 			}
 		}
 	}
 }
 
-func discoverDevices() []apiv1.Device {
-	// TODO: wire to NVML. For now emit a placeholder entry so flows can be tested.
-	return []apiv1.Device{
-		{
-			ID:        0,
-			Health:    "Unknown",
-			Bandwidth: 0,
-			Island:    "default",
-		},
+func newDiscoverer(backend string) (discovery.Discoverer, error) {
+	switch backend {
+	case discovery.BackendNVML:
+		return discovery.NewNVML(*hostRoot), nil
+	case discovery.BackendNvidiaSMI:
+		return discovery.NewNvidiaSMI(), nil
+	case discovery.BackendSysfs:
+		return discovery.NewSysfs(*hostRoot), nil
+	case discovery.BackendFake:
+		return discovery.NewFake(*fakeGPUs), nil
+	default:
+		return nil, fmt.Errorf("unknown discovery backend %q", backend)
 	}
 }
 
-func publishStatus(ctx context.Context, c client.Client, nodeName string, devices []apiv1.Device) error {
+func publishStatus(ctx context.Context, c client.Client, nodeName string, devices []apiv1.Device, links []apiv1.Link) error {
 	apply := &apiv1.GpuNodeStatus{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "gpu.scheduling/v1",
@@ -96,6 +118,7 @@ func publishStatus(ctx context.Context, c client.Client, nodeName string, device
 		},
 		Status: apiv1.GpuNodeStatusStatus{
 			Devices: devices,
+			Links:   links,
 			Total:   len(devices),
 		},
 	}
@@ -1,4 +1,4 @@
-// Package controllers holds future controller-runtime Reconcilers.
-// The MVP does not require controllers, but we keep the module in place
-// to satisfy kubebuilder layout expectations.
+// Package controllers holds controller-runtime Reconcilers.
+// GpuClaimReconciler runs inside the scheduler process and publishes the
+// lifecycle of each GpuClaim in its status.
 package controllers
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/alloc"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+const (
+	// DefaultUnschedulableTimeout is how long pods of a claim may stay
+	// unschedulable before the claim is marked Failed.
+	DefaultUnschedulableTimeout = 10 * time.Minute
+
+	// podClaimIndex indexes pods by the GpuClaim named in their annotation.
+	podClaimIndex = "gpu.scheduling/claim"
+)
+
+// GpuClaimReconciler derives GpuClaim status from the pods referencing the
+// claim and the GPUs reserved for them in GpuNodeAllocations.
+type GpuClaimReconciler struct {
+	client.Client
+	// UnschedulableTimeout defaults to DefaultUnschedulableTimeout.
+	UnschedulableTimeout time.Duration
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+// SetupWithManager registers the reconciler and its pod and allocation watches.
+func (r *GpuClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.UnschedulableTimeout <= 0 {
+		r.UnschedulableTimeout = DefaultUnschedulableTimeout
+	}
+	if r.Now == nil {
+		r.Now = time.Now
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podClaimIndex, indexPodClaim); err != nil {
+		return fmt.Errorf("index pods by claim: %w", err)
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.GpuClaim{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(podToClaim)).
+		Watches(&apiv1.GpuNodeAllocation{}, handler.EnqueueRequestsFromMapFunc(r.allocationToClaims)).
+		Complete(r)
+}
+
+// Reconcile recomputes the phase, allocation and conditions of one GpuClaim.
+func (r *GpuClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	claim := &apiv1.GpuClaim{}
+	if err := r.Get(ctx, req.NamespacedName, claim); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(claim.Namespace), client.MatchingFields{podClaimIndex: claim.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("list pods: %w", err)
+	}
+
+	status, requeue, err := r.observe(ctx, claim, pods.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(claim.Status, status) {
+		return ctrl.Result{RequeueAfter: requeue}, nil
+	}
+
+	patch := client.MergeFrom(claim.DeepCopy())
+	claim.Status = status
+	if err := r.Status().Patch(ctx, claim, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("patch status: %w", err)
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// observe computes the desired status. The most advanced pod wins: Bound over
+// Reserved over Pending; Failed only when no pod has GPUs and one has been
+// unschedulable past the timeout. requeue is set while that timeout is pending.
+func (r *GpuClaimReconciler) observe(ctx context.Context, claim *apiv1.GpuClaim, pods []corev1.Pod) (apiv1.GpuClaimStatus, time.Duration, error) {
+	status := *claim.Status.DeepCopy()
+	now := r.Now()
+
+	var (
+		boundPod    *corev1.Pod
+		boundIDs    []int
+		reservedPod *corev1.Pod
+		reservedOn  string
+		reservedIDs []int
+		stuckPod    *corev1.Pod
+		stuckMsg    string
+		requeue     time.Duration
+	)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if ids, ok := util.GetAllocated(pod); ok && pod.Spec.NodeName != "" {
+			if boundPod == nil {
+				boundPod, boundIDs = pod, ids
+			}
+			continue
+		}
+
+		node, ids, err := alloc.ForPod(ctx, r.Client, pod.UID)
+		if err != nil {
+			return status, 0, err
+		}
+		if len(ids) > 0 {
+			if reservedPod == nil {
+				reservedPod, reservedOn, reservedIDs = pod, node, ids
+			}
+			continue
+		}
+
+		cond := podScheduledCondition(pod)
+		if cond == nil || cond.Status != corev1.ConditionFalse || cond.Reason != corev1.PodReasonUnschedulable {
+			continue
+		}
+		waited := now.Sub(cond.LastTransitionTime.Time)
+		if waited >= r.UnschedulableTimeout {
+			if stuckPod == nil {
+				stuckPod, stuckMsg = pod, cond.Message
+			}
+			continue
+		}
+		if left := r.UnschedulableTimeout - waited; requeue == 0 || left < requeue {
+			requeue = left
+		}
+	}
+
+	switch {
+	case boundPod != nil:
+		setAllocation(&status, apiv1.ClaimBound, boundPod.Spec.NodeName, boundIDs)
+		setCondition(&status, claim, apiv1.ClaimConditionReserved, true, "GPUsReserved", fmt.Sprintf("GPUs held for pod %s", boundPod.Name))
+		setCondition(&status, claim, apiv1.ClaimConditionBound, true, "PodBound", fmt.Sprintf("pod %s bound to %s", boundPod.Name, boundPod.Spec.NodeName))
+		setCondition(&status, claim, apiv1.ClaimConditionSchedulable, true, "PodBound", "")
+		return status, 0, nil
+	case reservedPod != nil:
+		setAllocation(&status, apiv1.ClaimReserved, reservedOn, reservedIDs)
+		setCondition(&status, claim, apiv1.ClaimConditionReserved, true, "GPUsReserved", fmt.Sprintf("GPUs held for pod %s", reservedPod.Name))
+		setCondition(&status, claim, apiv1.ClaimConditionBound, false, "WaitingForBinding", "")
+		setCondition(&status, claim, apiv1.ClaimConditionSchedulable, true, "GPUsReserved", "")
+		return status, 0, nil
+	case stuckPod != nil:
+		setAllocation(&status, apiv1.ClaimFailed, "", nil)
+		status.Message = fmt.Sprintf("pod %s unschedulable for more than %s: %s", stuckPod.Name, r.UnschedulableTimeout, stuckMsg)
+		setCondition(&status, claim, apiv1.ClaimConditionReserved, false, "NoGPUs", "")
+		setCondition(&status, claim, apiv1.ClaimConditionBound, false, "NoGPUs", "")
+		setCondition(&status, claim, apiv1.ClaimConditionSchedulable, false, "UnschedulableTimeout", status.Message)
+		return status, 0, nil
+	default:
+		setAllocation(&status, apiv1.ClaimPending, "", nil)
+		setCondition(&status, claim, apiv1.ClaimConditionReserved, false, "NoGPUs", "")
+		setCondition(&status, claim, apiv1.ClaimConditionBound, false, "NoGPUs", "")
+		meta.RemoveStatusCondition(&status.Conditions, apiv1.ClaimConditionSchedulable)
+		return status, requeue, nil
+	}
+}
+
+// allocationToClaims maps a GpuNodeAllocation to the claims of the pods holding GPUs in it.
+func (r *GpuClaimReconciler) allocationToClaims(ctx context.Context, obj client.Object) []reconcile.Request {
+	na, ok := obj.(*apiv1.GpuNodeAllocation)
+	if !ok {
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, ref := range alloc.PodsOf(na) {
+		pod := &corev1.Pod{}
+		if err := r.Get(ctx, ref, pod); err != nil {
+			if !apierrors.IsNotFound(err) {
+				ctrl.LoggerFrom(ctx).Error(err, "map allocation to claim", "node", na.Name, "pod", ref)
+			}
+			continue
+		}
+		reqs = append(reqs, podToClaim(ctx, pod)...)
+	}
+	return reqs
+}
+
+func podToClaim(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetAnnotations()[util.AnnoClaim]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+}
+
+func indexPodClaim(obj client.Object) []string {
+	if name := obj.GetAnnotations()[util.AnnoClaim]; name != "" {
+		return []string{name}
+	}
+	return nil
+}
+
+func podScheduledCondition(pod *corev1.Pod) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == corev1.PodScheduled {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func setAllocation(status *apiv1.GpuClaimStatus, phase, node string, ids []int) {
+	if status.Phase == apiv1.ClaimFailed && phase != apiv1.ClaimFailed {
+		status.Message = ""
+	}
+	status.Phase = phase
+	status.NodeName = node
+	status.GPUIds = ids
+	status.Allocated = ""
+	if node != "" {
+		parts := make([]string, len(ids))
+		for i, id := range ids {
+			parts[i] = strconv.Itoa(id)
+		}
+		status.Allocated = node + ":" + strings.Join(parts, ",")
+	}
+}
+
+func setCondition(status *apiv1.GpuClaimStatus, claim *apiv1.GpuClaim, condType string, ok bool, reason, msg string) {
+	cond := metav1.Condition{
+		Type:               condType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: claim.Generation,
+		Reason:             reason,
+		Message:            msg,
+	}
+	if ok {
+		cond.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, cond)
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+func TestReconcilePhase(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	claim := func() *apiv1.GpuClaim {
+		return &apiv1.GpuClaim{ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "default"}}
+	}
+	pod := func(name string, mutate func(*corev1.Pod)) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			UID:         types.UID("uid-" + name),
+			Annotations: map[string]string{util.AnnoClaim: "train"},
+		}}
+		mutate(p)
+		return p
+	}
+	unschedulableSince := func(d time.Duration) func(*corev1.Pod) {
+		return func(p *corev1.Pod) {
+			p.Status.Conditions = []corev1.PodCondition{{
+				Type:               corev1.PodScheduled,
+				Status:             corev1.ConditionFalse,
+				Reason:             corev1.PodReasonUnschedulable,
+				Message:            "0/3 nodes are available",
+				LastTransitionTime: metav1.NewTime(now.Add(-d)),
+			}}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		objs      []client.Object
+		phase     string
+		node      string
+		ids       []int
+		allocated string
+		requeue   bool
+	}{
+		{
+			name:  "no pods",
+			objs:  []client.Object{claim()},
+			phase: apiv1.ClaimPending,
+		},
+		{
+			name: "bound",
+			objs: []client.Object{claim(), pod("bound", func(p *corev1.Pod) {
+				p.Spec.NodeName = "node-a"
+				util.SetAllocated(p, "node-a", []int{2, 3})
+			})},
+			phase:     apiv1.ClaimBound,
+			node:      "node-a",
+			ids:       []int{2, 3},
+			allocated: "node-a:2,3",
+		},
+		{
+			name: "reserved",
+			objs: []client.Object{
+				claim(),
+				pod("reserved", func(*corev1.Pod) {}),
+				&apiv1.GpuNodeAllocation{
+					ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+					Spec: apiv1.GpuNodeAllocationSpec{
+						NodeName: "node-b",
+						Allocations: []apiv1.PodAllocation{
+							{Namespace: "default", Name: "reserved", UID: "uid-reserved", GPUIds: []int{1}},
+						},
+					},
+				},
+			},
+			phase:     apiv1.ClaimReserved,
+			node:      "node-b",
+			ids:       []int{1},
+			allocated: "node-b:1",
+		},
+		{
+			name:    "unschedulable within timeout",
+			objs:    []client.Object{claim(), pod("waiting", unschedulableSince(time.Minute))},
+			phase:   apiv1.ClaimPending,
+			requeue: true,
+		},
+		{
+			name:  "unschedulable past timeout",
+			objs:  []client.Object{claim(), pod("stuck", unschedulableSince(time.Hour))},
+			phase: apiv1.ClaimFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			utilruntime.Must(apiv1.AddToScheme(scheme))
+
+			c := crfake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(tt.objs...).
+				WithStatusSubresource(&apiv1.GpuClaim{}).
+				WithIndex(&corev1.Pod{}, podClaimIndex, indexPodClaim).
+				Build()
+			r := &GpuClaimReconciler{
+				Client:               c,
+				UnschedulableTimeout: DefaultUnschedulableTimeout,
+				Now:                  func() time.Time { return now },
+			}
+
+			key := types.NamespacedName{Namespace: "default", Name: "train"}
+			res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile: %v", err)
+			}
+			if got := res.RequeueAfter > 0; got != tt.requeue {
+				t.Errorf("requeue = %v, want %v", res.RequeueAfter, tt.requeue)
+			}
+
+			got := &apiv1.GpuClaim{}
+			if err := c.Get(ctx, key, got); err != nil {
+				t.Fatal(err)
+			}
+			st := got.Status
+			if st.Phase != tt.phase || st.NodeName != tt.node || st.Allocated != tt.allocated || !reflect.DeepEqual(st.GPUIds, tt.ids) {
+				t.Errorf("status = %+v, want phase=%s node=%s ids=%v allocated=%s", st, tt.phase, tt.node, tt.ids, tt.allocated)
+			}
+			bound := meta.IsStatusConditionTrue(st.Conditions, apiv1.ClaimConditionBound)
+			if bound != (tt.phase == apiv1.ClaimBound) {
+				t.Errorf("Bound condition = %v for phase %s", bound, tt.phase)
+			}
+			if tt.phase == apiv1.ClaimFailed && !meta.IsStatusConditionFalse(st.Conditions, apiv1.ClaimConditionSchedulable) {
+				t.Errorf("Schedulable condition not False for failed claim: %+v", st.Conditions)
+			}
+		})
+	}
+}
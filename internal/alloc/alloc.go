@@ -0,0 +1,203 @@
+// Package alloc maintains the GpuNodeAllocation ledger recording which pod
+// holds which GPUs on each node.
+package alloc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+)
+
+// ErrTaken reports that a requested GPU is held by another pod.
+var ErrTaken = errors.New("GPU already allocated")
+
+// Set maps node name and GPU id to the UID of the pod holding it.
+type Set map[string]map[int]types.UID
+
+// Has reports whether the GPU is held by any pod.
+func (s Set) Has(node string, id int) bool {
+	_, ok := s[node][id]
+	return ok
+}
+
+// Add records the GPU as held by the pod with the given UID.
+func (s Set) Add(node string, id int, uid types.UID) {
+	if s[node] == nil {
+		s[node] = map[int]types.UID{}
+	}
+	s[node][id] = uid
+}
+
+// Clone returns a deep copy of the set.
+func (s Set) Clone() Set {
+	out := make(Set, len(s))
+	for node, ids := range s {
+		out[node] = maps.Clone(ids)
+	}
+	return out
+}
+
+// Drop removes every GPU the pod holds on node and returns their ids.
+func (s Set) Drop(node string, uid types.UID) []int {
+	var out []int
+	for id, holder := range s[node] {
+		if holder == uid {
+			delete(s[node], id)
+			out = append(out, id)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Acquire reserves every id on node for pod in a single write, or none of
+// them. A previous reservation of the same pod on that node is replaced, so a
+// pod re-entering Reserve after a scheduler restart does not block itself.
+func Acquire(ctx context.Context, c client.Client, node string, pod *corev1.Pod, ids []int) error {
+	return update(ctx, c, node, func(spec *apiv1.GpuNodeAllocationSpec) (bool, error) {
+		var taken []int
+		for _, a := range spec.Allocations {
+			if a.UID == string(pod.UID) {
+				continue
+			}
+			for _, id := range a.GPUIds {
+				if slices.Contains(ids, id) {
+					taken = append(taken, id)
+				}
+			}
+		}
+		if len(taken) > 0 {
+			sort.Ints(taken)
+			return false, fmt.Errorf("%w: %v on node %s", ErrTaken, taken, node)
+		}
+		spec.Allocations = slices.DeleteFunc(spec.Allocations, func(a apiv1.PodAllocation) bool {
+			return a.UID == string(pod.UID)
+		})
+		spec.Allocations = append(spec.Allocations, apiv1.PodAllocation{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       string(pod.UID),
+			GPUIds:    append([]int(nil), ids...),
+		})
+		return true, nil
+	})
+}
+
+// Release drops every GPU held by the pod with the given UID on node.
+func Release(ctx context.Context, c client.Client, node string, uid types.UID) error {
+	return update(ctx, c, node, func(spec *apiv1.GpuNodeAllocationSpec) (bool, error) {
+		n := len(spec.Allocations)
+		spec.Allocations = slices.DeleteFunc(spec.Allocations, func(a apiv1.PodAllocation) bool {
+			return a.UID == string(uid)
+		})
+		return len(spec.Allocations) != n, nil
+	})
+}
+
+// Held returns every allocated GPU in the cluster. GPU leases written by
+// releases before GpuNodeAllocation still count until the GC migrates them.
+func Held(ctx context.Context, c client.Reader) (Set, error) {
+	allocs := &apiv1.GpuNodeAllocationList{}
+	if err := c.List(ctx, allocs); err != nil {
+		return nil, fmt.Errorf("list GpuNodeAllocations: %w", err)
+	}
+	leases := &coordv1.LeaseList{}
+	if err := c.List(ctx, leases, client.MatchingLabelsSelector{Selector: lease.ManagedSelector()}); err != nil {
+		return nil, fmt.Errorf("list GPU leases: %w", err)
+	}
+
+	out := Set{}
+	for _, na := range allocs.Items {
+		for _, a := range na.Spec.Allocations {
+			for _, id := range a.GPUIds {
+				out.Add(na.Name, id, types.UID(a.UID))
+			}
+		}
+	}
+	for i := range leases.Items {
+		l := &leases.Items[i]
+		if node, id, ok := lease.Allocation(l); ok {
+			var holder types.UID
+			if l.Spec.HolderIdentity != nil {
+				holder = types.UID(*l.Spec.HolderIdentity)
+			}
+			out.Add(node, id, holder)
+		}
+	}
+	return out, nil
+}
+
+// ForPod returns the node and sorted GPU ids reserved for the pod with the
+// given UID, or an empty node if it holds none.
+func ForPod(ctx context.Context, c client.Reader, uid types.UID) (string, []int, error) {
+	allocs := &apiv1.GpuNodeAllocationList{}
+	if err := c.List(ctx, allocs); err != nil {
+		return "", nil, fmt.Errorf("list GpuNodeAllocations: %w", err)
+	}
+	for _, na := range allocs.Items {
+		for _, a := range na.Spec.Allocations {
+			if a.UID == string(uid) && len(a.GPUIds) > 0 {
+				ids := append([]int(nil), a.GPUIds...)
+				sort.Ints(ids)
+				return na.Name, ids, nil
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// PodsOf returns the pods holding GPUs in an allocation object.
+func PodsOf(na *apiv1.GpuNodeAllocation) []types.NamespacedName {
+	out := make([]types.NamespacedName, 0, len(na.Spec.Allocations))
+	for _, a := range na.Spec.Allocations {
+		out = append(out, types.NamespacedName{Namespace: a.Namespace, Name: a.Name})
+	}
+	return out
+}
+
+// update applies mutate to the node's allocation object, creating it on first
+// use. Writes carry the resourceVersion that was read, so a concurrent writer
+// makes the API server reject the update and mutate runs again on fresh state.
+func update(ctx context.Context, c client.Client, node string, mutate func(*apiv1.GpuNodeAllocationSpec) (bool, error)) error {
+	return retry.OnError(retry.DefaultRetry, retriable, func() error {
+		na := &apiv1.GpuNodeAllocation{}
+		err := c.Get(ctx, types.NamespacedName{Name: node}, na)
+		create := apierrors.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		if create {
+			na = &apiv1.GpuNodeAllocation{
+				ObjectMeta: metav1.ObjectMeta{Name: node},
+				Spec:       apiv1.GpuNodeAllocationSpec{NodeName: node},
+			}
+		}
+
+		changed, err := mutate(&na.Spec)
+		if err != nil || !changed {
+			return err
+		}
+		if create {
+			return c.Create(ctx, na)
+		}
+		return c.Update(ctx, na)
+	})
+}
+
+func retriable(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
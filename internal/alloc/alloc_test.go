@@ -0,0 +1,139 @@
+package alloc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiv1.AddToScheme(scheme))
+	return scheme
+}
+
+func testPod(name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)}}
+}
+
+func allocations(t *testing.T, c client.Client, node string) []apiv1.PodAllocation {
+	t.Helper()
+	na := &apiv1.GpuNodeAllocation{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: node}, na); err != nil {
+		t.Fatalf("get GpuNodeAllocation %s: %v", node, err)
+	}
+	return na.Spec.Allocations
+}
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	c := crfake.NewClientBuilder().WithScheme(newScheme()).Build()
+
+	if err := Acquire(ctx, c, "node-a", testPod("a"), []int{0, 1}); err != nil {
+		t.Fatalf("Acquire a: %v", err)
+	}
+	if err := Acquire(ctx, c, "node-a", testPod("b"), []int{1, 2}); !errors.Is(err, ErrTaken) {
+		t.Fatalf("Acquire b = %v, want ErrTaken", err)
+	}
+	if err := Acquire(ctx, c, "node-a", testPod("b"), []int{2, 3}); err != nil {
+		t.Fatalf("Acquire b: %v", err)
+	}
+	// Re-reserving replaces the pod's previous entry instead of adding to it.
+	if err := Acquire(ctx, c, "node-a", testPod("a"), []int{4}); err != nil {
+		t.Fatalf("re-Acquire a: %v", err)
+	}
+	if got := fmt.Sprint(allocations(t, c, "node-a")); got != "[{default b uid-b [2 3]} {default a uid-a [4]}]" {
+		t.Errorf("allocations = %s", got)
+	}
+
+	held, err := Held(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int]bool{0: false, 1: false, 2: true, 3: true, 4: true} {
+		if held.Has("node-a", id) != want {
+			t.Errorf("Held.Has(node-a, %d) = %v, want %v", id, !want, want)
+		}
+	}
+
+	if err := Release(ctx, c, "node-a", "uid-b"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	node, ids, err := ForPod(ctx, c, "uid-b")
+	if err != nil || node != "" || ids != nil {
+		t.Errorf("ForPod after Release = %q, %v, %v", node, ids, err)
+	}
+	if node, ids, _ := ForPod(ctx, c, "uid-a"); node != "node-a" || fmt.Sprint(ids) != "[4]" {
+		t.Errorf("ForPod(uid-a) = %q, %v", node, ids)
+	}
+}
+
+func TestAcquireConcurrentWrite(t *testing.T) {
+	ctx := context.Background()
+	existing := &apiv1.GpuNodeAllocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Spec:       apiv1.GpuNodeAllocationSpec{NodeName: "node-a"},
+	}
+
+	tests := []struct {
+		name    string
+		rival   []int
+		wantErr error
+		want    string
+	}{
+		{
+			name:  "disjoint GPUs retry and both land",
+			rival: []int{3},
+			want:  "[{default rival uid-rival [3]} {default a uid-a [0 1]}]",
+		},
+		{
+			name:    "overlapping GPUs reject the whole pick",
+			rival:   []int{1},
+			wantErr: ErrTaken,
+			want:    "[{default rival uid-rival [1]}]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raced := false
+			c := crfake.NewClientBuilder().
+				WithScheme(newScheme()).
+				WithObjects(existing.DeepCopy()).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+						// Another scheduler writes between our read and our update.
+						if !raced {
+							raced = true
+							if err := Acquire(ctx, c, "node-a", testPod("rival"), tt.rival); err != nil {
+								return err
+							}
+						}
+						return c.Update(ctx, obj, opts...)
+					},
+				}).
+				Build()
+
+			err := Acquire(ctx, c, "node-a", testPod("a"), []int{0, 1})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Acquire = %v, want %v", err, tt.wantErr)
+			}
+			if got := fmt.Sprint(allocations(t, c, "node-a")); got != tt.want {
+				t.Errorf("allocations = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package alloc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+)
+
+// StartGC runs a background loop that, every interval, releases GPUs of
+// finished or deleted pods and of pods bound to another node, and folds
+// legacy GPU leases into the ledger. c may read pods from a cache; a pod it
+// reports stale is confirmed through live, which reads the API server, so a
+// lagging cache cannot free the GPUs of a pod that was just reserved.
+func StartGC(ctx context.Context, c client.Client, live client.Reader, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runGC(ctx, c, live)
+			}
+		}
+	}()
+}
+
+func runGC(ctx context.Context, c client.Client, live client.Reader) {
+	allocs := &apiv1.GpuNodeAllocationList{}
+	if err := c.List(ctx, allocs); err != nil {
+		klog.ErrorS(err, "GC: failed to list GpuNodeAllocations")
+	}
+	for _, na := range allocs.Items {
+		var gone []string
+		for _, a := range na.Spec.Allocations {
+			ref := types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+			reason, err := confirmStale(ctx, c, live, ref, a.UID, na.Name)
+			if err != nil {
+				klog.ErrorS(err, "GC: failed to get pod", "pod", ref)
+				continue
+			}
+			if reason != "" {
+				klog.InfoS("GC: releasing GPUs", "reason", reason, "node", na.Name, "pod", ref, "gpuIDs", a.GPUIds)
+				gone = append(gone, a.UID)
+			}
+		}
+		if len(gone) == 0 {
+			continue
+		}
+		err := update(ctx, c, na.Name, func(spec *apiv1.GpuNodeAllocationSpec) (bool, error) {
+			spec.Allocations = slices.DeleteFunc(spec.Allocations, func(a apiv1.PodAllocation) bool {
+				return slices.Contains(gone, a.UID)
+			})
+			return true, nil
+		})
+		if err != nil {
+			klog.ErrorS(err, "GC: failed to update GpuNodeAllocation", "node", na.Name)
+		}
+	}
+
+	migrateLeases(ctx, c, live)
+}
+
+// migrateLeases moves GPU leases written by older releases into the ledger.
+// Leases of finished pods are simply deleted. If the ledger already gives the
+// GPU to another pod, the GPU was double-booked; the lease is kept so the GPU
+// stays busy until GC removes it with its pod.
+func migrateLeases(ctx context.Context, c client.Client, live client.Reader) {
+	leases := &coordv1.LeaseList{}
+	if err := c.List(ctx, leases, client.MatchingLabelsSelector{Selector: lease.ManagedSelector()}); err != nil {
+		klog.ErrorS(err, "GC: failed to list leases")
+		return
+	}
+
+	for i := range leases.Items {
+		l := &leases.Items[i]
+		ref := lease.PodOf(l)
+		if ref.Name == "" {
+			continue
+		}
+		var holder string
+		if l.Spec.HolderIdentity != nil {
+			holder = *l.Spec.HolderIdentity
+		}
+
+		node, id, ok := lease.Allocation(l)
+		reason, err := confirmStale(ctx, c, live, ref, holder, node)
+		if err != nil {
+			klog.ErrorS(err, "GC: failed to get pod", "pod", ref)
+			continue
+		}
+		if reason != "" {
+			klog.InfoS("GC: deleting lease", "reason", reason, "lease", l.Name, "pod", ref)
+			deleteLease(ctx, c, l)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		err = update(ctx, c, node, func(spec *apiv1.GpuNodeAllocationSpec) (bool, error) {
+			return addGPU(spec, ref, holder, id)
+		})
+		if errors.Is(err, ErrTaken) {
+			klog.InfoS("GC: GPU double-booked, keeping legacy lease", "lease", l.Name, "namespace", l.Namespace)
+			continue
+		}
+		if err != nil {
+			klog.ErrorS(err, "GC: failed to migrate lease", "lease", l.Name, "namespace", l.Namespace)
+			continue
+		}
+		klog.InfoS("GC: migrated lease", "lease", l.Name, "namespace", l.Namespace, "node", node)
+		deleteLease(ctx, c, l)
+	}
+}
+
+// addGPU adds one GPU to the pod's entry, creating the entry if needed.
+func addGPU(spec *apiv1.GpuNodeAllocationSpec, ref types.NamespacedName, uid string, id int) (bool, error) {
+	entry := -1
+	for i, a := range spec.Allocations {
+		if a.UID == uid {
+			entry = i
+			continue
+		}
+		if slices.Contains(a.GPUIds, id) {
+			return false, ErrTaken
+		}
+	}
+	if entry < 0 {
+		spec.Allocations = append(spec.Allocations, apiv1.PodAllocation{Namespace: ref.Namespace, Name: ref.Name, UID: uid})
+		entry = len(spec.Allocations) - 1
+	}
+	if slices.Contains(spec.Allocations[entry].GPUIds, id) {
+		return false, nil
+	}
+	spec.Allocations[entry].GPUIds = append(spec.Allocations[entry].GPUIds, id)
+	return true, nil
+}
+
+// confirmStale is stale read through c, checked again through live when c
+// finds the pod stale.
+func confirmStale(ctx context.Context, c, live client.Reader, ref types.NamespacedName, uid, node string) (string, error) {
+	reason, err := stale(ctx, c, ref, uid, node)
+	if err != nil || reason == "" {
+		return reason, err
+	}
+	return stale(ctx, live, ref, uid, node)
+}
+
+// stale explains why a pod no longer needs its GPUs on node, or returns "" if
+// it does. A pod bound elsewhere left the entry behind when the scheduler
+// failed between Reserve and bind and later placed the pod on another node.
+func stale(ctx context.Context, c client.Reader, ref types.NamespacedName, uid, node string) (string, error) {
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, ref, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "missing pod", nil
+		}
+		return "", err
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "completed/failed pod", nil
+	}
+	if uid != "" && string(pod.UID) != uid {
+		return "UID mismatch", nil
+	}
+	if node != "" && pod.Spec.NodeName != "" && pod.Spec.NodeName != node {
+		return "pod bound to another node", nil
+	}
+	return "", nil
+}
+
+func deleteLease(ctx context.Context, c client.Client, l *coordv1.Lease) {
+	if err := c.Delete(ctx, l); err != nil && !apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "GC: failed to delete lease", "lease", l.Name, "namespace", l.Namespace)
+	}
+}
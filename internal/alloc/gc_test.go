@@ -0,0 +1,149 @@
+package alloc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+)
+
+func TestRunGC(t *testing.T) {
+	ctx := context.Background()
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	entry := func(name, uid string, ids ...int) apiv1.PodAllocation {
+		return apiv1.PodAllocation{Namespace: "default", Name: name, UID: uid, GPUIds: ids}
+	}
+
+	c := crfake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+		pod("running", corev1.PodRunning),
+		pod("completed", corev1.PodSucceeded),
+		pod("recreated", corev1.PodRunning),
+		// Reserved on node-a, then rescheduled to node-b after a crash before bind.
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "moved", Namespace: "default", UID: "uid-moved"},
+			Spec:       corev1.PodSpec{NodeName: "node-b"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		// Reserved on node-a and not bound yet.
+		pod("binding", corev1.PodPending),
+		&apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Spec: apiv1.GpuNodeAllocationSpec{NodeName: "node-a", Allocations: []apiv1.PodAllocation{
+				entry("running", "uid-running", 0),
+				entry("missing", "uid-missing", 1),
+				entry("completed", "uid-completed", 2),
+				entry("recreated", "uid-old", 3),
+				entry("moved", "uid-moved", 4),
+				entry("binding", "uid-binding", 5),
+			}},
+		},
+	).Build()
+
+	runGC(ctx, c, c)
+
+	if got := fmt.Sprint(allocations(t, c, "node-a")); got != "[{default running uid-running [0]} {default binding uid-binding [5]}]" {
+		t.Errorf("allocations = %s, want only the running and binding pods", got)
+	}
+}
+
+// The cache lags the API server: it has not seen a pod reserved a moment ago,
+// nor the new incarnation of a recreated pod. Neither may lose its GPUs.
+func TestRunGCConfirmsWithAPIServer(t *testing.T) {
+	ctx := context.Background()
+	pod := func(name, uid string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(uid)},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		}
+	}
+	cached := crfake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+		pod("recreated", "uid-old"),
+		&apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Spec: apiv1.GpuNodeAllocationSpec{NodeName: "node-a", Allocations: []apiv1.PodAllocation{
+				{Namespace: "default", Name: "reserved", UID: "uid-reserved", GPUIds: []int{0}},
+				{Namespace: "default", Name: "recreated", UID: "uid-new", GPUIds: []int{1}},
+				{Namespace: "default", Name: "gone", UID: "uid-gone", GPUIds: []int{2}},
+			}},
+		},
+	).Build()
+	live := crfake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+		pod("reserved", "uid-reserved"),
+		pod("recreated", "uid-new"),
+	).Build()
+
+	runGC(ctx, cached, live)
+
+	if got := fmt.Sprint(allocations(t, cached, "node-a")); got != "[{default reserved uid-reserved [0]} {default recreated uid-new [1]}]" {
+		t.Errorf("allocations = %s, want the pods the API server still has", got)
+	}
+}
+
+func TestRunGCMigratesLegacyLeases(t *testing.T) {
+	ctx := context.Background()
+	pod := func(ns, name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, UID: types.UID("uid-" + name)},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	legacy := func(ns, node string, id int, podName string) *coordv1.Lease {
+		holder := "uid-" + podName
+		return &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: lease.LeaseName(node, id), Namespace: ns, Labels: map[string]string{
+				"gpu.scheduling/managed": "true",
+				"gpu.scheduling/pod":     podName,
+			}},
+			Spec: coordv1.LeaseSpec{HolderIdentity: &holder},
+		}
+	}
+
+	c := crfake.NewClientBuilder().WithScheme(newScheme()).WithObjects(
+		// Two leases of one pod on a node whose name contains dashes.
+		pod("team-a", "trainer"),
+		legacy("team-a", "gpu-node-a", 3, "trainer"),
+		legacy("team-a", "gpu-node-a", 4, "trainer"),
+		// Lease of a pod that no longer exists.
+		legacy("team-a", "gpu-node-a", 5, "gone"),
+		// GPU double-booked: the ledger already holds it for another pod.
+		pod("team-c", "intruder"),
+		legacy("team-c", "node-b", 0, "intruder"),
+		&apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+			Spec: apiv1.GpuNodeAllocationSpec{NodeName: "node-b", Allocations: []apiv1.PodAllocation{
+				{Namespace: "team-b", Name: "owner", UID: "uid-owner", GPUIds: []int{0}},
+			}},
+		},
+		pod("team-b", "owner"),
+	).Build()
+
+	runGC(ctx, c, c)
+
+	if got := fmt.Sprint(allocations(t, c, "gpu-node-a")); got != "[{team-a trainer uid-trainer [3 4]}]" {
+		t.Errorf("gpu-node-a allocations = %s", got)
+	}
+	if got := fmt.Sprint(allocations(t, c, "node-b")); got != "[{team-b owner uid-owner [0]}]" {
+		t.Errorf("node-b allocations = %s, want owner unchanged", got)
+	}
+
+	leases := &coordv1.LeaseList{}
+	if err := c.List(ctx, leases); err != nil {
+		t.Fatal(err)
+	}
+	if len(leases.Items) != 1 || leases.Items[0].Namespace != "team-c" {
+		t.Errorf("remaining leases = %v, want only the double-booked lease in team-c", leases.Items)
+	}
+}
@@ -0,0 +1,171 @@
+// Package discovery finds the GPUs on the agent's host and converts them into
+// the devices published in GpuNodeStatus.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/topo"
+)
+
+// Discovery backends selectable with the agent's --discovery flag.
+const (
+	BackendNVML      = "nvml"
+	BackendNvidiaSMI = "nvidia-smi"
+	BackendSysfs     = "sysfs"
+	BackendFake      = "fake"
+)
+
+// GPU is a device found on the host.
+type GPU struct {
+	Index     int
+	UUID      string
+	Name      string
+	MemoryMiB int
+	// PCIBusID is the sysfs form of the PCI address, e.g. 0000:07:00.0.
+	PCIBusID string
+	// PCIeRoot is the PCIe root port the GPU sits under.
+	PCIeRoot string
+	// NUMANode is nil when the host does not report one.
+	NUMANode *int
+	// Links maps each peer's index to the GPU's connection to it.
+	Links map[int]Link
+	// NVLinkGBps is the summed bandwidth of the GPU's active NVLinks.
+	NVLinkGBps int
+	Health     string
+	// ComputeCapability is major.minor, e.g. 9.0.
+	ComputeCapability string
+	DriverVersion     string
+	CUDAVersion       string
+	// MIG is Enabled or Disabled, and empty without MIG support.
+	MIG string
+}
+
+// Discoverer lists the GPUs on the host.
+type Discoverer interface {
+	Discover(ctx context.Context) ([]GPU, error)
+}
+
+// Devices converts discovered GPUs into GpuNodeStatus devices. GPUs joined by
+// NVLink, directly or through peers, share an island named after its lowest
+// index; a GPU without NVLink is an island of its own.
+func Devices(gpus []GPU) []apiv1.Device {
+	parent := make(map[int]int, len(gpus))
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, g := range gpus {
+		parent[g.Index] = g.Index
+	}
+	for _, g := range gpus {
+		for peer, l := range g.Links {
+			if _, ok := parent[peer]; !ok || l.NVLinks == 0 {
+				continue
+			}
+			a, b := find(g.Index), find(peer)
+			parent[max(a, b)] = min(a, b)
+		}
+	}
+
+	out := make([]apiv1.Device, 0, len(gpus))
+	for _, g := range gpus {
+		health := g.Health
+		if health == "" {
+			health = apiv1.HealthUnknown
+		}
+		island := fmt.Sprintf("gpu-%d", g.Index)
+		if nvlinked(g) {
+			island = fmt.Sprintf("nvlink-%d", find(g.Index))
+		}
+		out = append(out, apiv1.Device{
+			ID:        g.Index,
+			Health:    health,
+			Bandwidth: g.NVLinkGBps,
+			Island:    island,
+			PCIeRoot:  g.PCIeRoot,
+			PCIBusID:  g.PCIBusID,
+			NUMANode:  g.NUMANode,
+
+			UUID:              g.UUID,
+			Product:           g.Name,
+			MemoryMiB:         g.MemoryMiB,
+			ComputeCapability: g.ComputeCapability,
+			DriverVersion:     g.DriverVersion,
+			CUDAVersion:       g.CUDAVersion,
+			MIG:               g.MIG,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Links converts discovered GPU links into the GpuNodeStatus link list, each
+// pair once. System pairs are left out since the scheduler assumes them. A
+// GPU whose NVLinks to its peers add up to more than it has runs them through
+// NVSwitch, as NVSwitch systems report every peer at the full link count.
+func Links(gpus []GPU) []apiv1.Link {
+	var out []apiv1.Link
+	for _, g := range gpus {
+		switched := switchedNVLink(g)
+		for peer, l := range g.Links {
+			if peer <= g.Index {
+				continue
+			}
+			typ := linkType(l, switched)
+			if typ == topo.LinkSystem {
+				continue
+			}
+			out = append(out, apiv1.Link{A: g.Index, B: peer, Type: string(typ), Bandwidth: l.GBps})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].A != out[j].A {
+			return out[i].A < out[j].A
+		}
+		return out[i].B < out[j].B
+	})
+	return out
+}
+
+// switchedNVLink reports whether the GPU's per-peer NVLink bandwidth exceeds
+// its total NVLink bandwidth.
+func switchedNVLink(g GPU) bool {
+	sum := 0
+	for _, l := range g.Links {
+		sum += l.GBps
+	}
+	return g.NVLinkGBps > 0 && sum > g.NVLinkGBps
+}
+
+// linkType maps an nvidia-smi topo code onto the scheduler's link types.
+func linkType(l Link, switched bool) topo.LinkType {
+	switch {
+	case l.NVLinks > 0 && switched:
+		return topo.LinkNVSwitch
+	case l.NVLinks > 0:
+		return topo.LinkNVLink
+	case l.Type == LinkPIX || l.Type == LinkPXB:
+		return topo.LinkPCIeSwitch
+	case l.Type == LinkPHB || l.Type == LinkNODE:
+		return topo.LinkSocket
+	default:
+		return topo.LinkSystem
+	}
+}
+
+// nvlinked reports whether the GPU has an NVLink to any peer.
+func nvlinked(g GPU) bool {
+	for _, l := range g.Links {
+		if l.NVLinks > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+func nv(n int) Link { return Link{Type: fmt.Sprintf("NV%d", n), NVLinks: n} }
+
+func TestDevices(t *testing.T) {
+	gpus := []GPU{
+		{Index: 3, Links: map[int]Link{2: nv(12), 4: {Type: LinkSYS}}, NVLinkGBps: 300, Health: apiv1.HealthUnhealthy},
+		{Index: 0, Links: map[int]Link{1: nv(4)}, NVLinkGBps: 100, Health: apiv1.HealthHealthy},
+		{Index: 1, Links: map[int]Link{0: nv(4), 2: nv(4)}, NVLinkGBps: 200, Health: apiv1.HealthHealthy},
+		{Index: 2, Links: map[int]Link{1: nv(4), 3: nv(12)}, NVLinkGBps: 400, Health: apiv1.HealthHealthy},
+		{Index: 4, Links: map[int]Link{3: {Type: LinkSYS}}},
+	}
+	want := []apiv1.Device{
+		{ID: 0, Health: apiv1.HealthHealthy, Bandwidth: 100, Island: "nvlink-0"},
+		{ID: 1, Health: apiv1.HealthHealthy, Bandwidth: 200, Island: "nvlink-0"},
+		{ID: 2, Health: apiv1.HealthHealthy, Bandwidth: 400, Island: "nvlink-0"},
+		{ID: 3, Health: apiv1.HealthUnhealthy, Bandwidth: 300, Island: "nvlink-0"},
+		{ID: 4, Health: apiv1.HealthUnknown, Island: "gpu-4"},
+	}
+	if got := Devices(gpus); !reflect.DeepEqual(got, want) {
+		t.Errorf("Devices = %+v, want %+v", got, want)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		gpus []GPU
+		want []apiv1.Link
+	}{
+		{
+			// Each GPU has 12 links in total but reports NV12 to every peer.
+			name: "NVSwitch",
+			gpus: []GPU{
+				{Index: 0, NVLinkGBps: 300, Links: map[int]Link{1: {Type: "NV12", NVLinks: 12, GBps: 300}, 2: {Type: "NV12", NVLinks: 12, GBps: 300}}},
+				{Index: 1, NVLinkGBps: 300, Links: map[int]Link{0: {Type: "NV12", NVLinks: 12, GBps: 300}, 2: {Type: "NV12", NVLinks: 12, GBps: 300}}},
+				{Index: 2, NVLinkGBps: 300, Links: map[int]Link{0: {Type: "NV12", NVLinks: 12, GBps: 300}, 1: {Type: "NV12", NVLinks: 12, GBps: 300}}},
+			},
+			want: []apiv1.Link{
+				{A: 0, B: 1, Type: "NVSwitch", Bandwidth: 300},
+				{A: 0, B: 2, Type: "NVSwitch", Bandwidth: 300},
+				{A: 1, B: 2, Type: "NVSwitch", Bandwidth: 300},
+			},
+		},
+		{
+			name: "bridged pairs over PCIe",
+			gpus: []GPU{
+				{Index: 0, NVLinkGBps: 56, Links: map[int]Link{1: {Type: "NV4", NVLinks: 4, GBps: 56}, 2: {Type: LinkNODE}, 3: {Type: LinkSYS}}},
+				{Index: 1, NVLinkGBps: 56, Links: map[int]Link{0: {Type: "NV4", NVLinks: 4, GBps: 56}, 2: {Type: LinkPIX}, 3: {Type: LinkSYS}}},
+				{Index: 2, NVLinkGBps: 56, Links: map[int]Link{0: {Type: LinkNODE}, 1: {Type: LinkPIX}, 3: {Type: "NV4", NVLinks: 4, GBps: 56}}},
+				{Index: 3, NVLinkGBps: 56, Links: map[int]Link{0: {Type: LinkSYS}, 1: {Type: LinkSYS}, 2: {Type: "NV4", NVLinks: 4, GBps: 56}}},
+			},
+			want: []apiv1.Link{
+				{A: 0, B: 1, Type: "NVLink", Bandwidth: 56},
+				{A: 0, B: 2, Type: "Socket"},
+				{A: 1, B: 2, Type: "PCIeSwitch"},
+				{A: 2, B: 3, Type: "NVLink", Bandwidth: 56},
+			},
+		},
+		{
+			name: "no links",
+			gpus: []GPU{{Index: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Links(tt.gpus); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Links = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFake(t *testing.T) {
+	gpus, err := NewFake(4).Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	devs := Devices(gpus)
+	if len(devs) != 4 {
+		t.Fatalf("got %d devices, want 4", len(devs))
+	}
+	for _, d := range devs {
+		if d.Island != "nvlink-0" || d.Health != apiv1.HealthHealthy {
+			t.Errorf("device %+v, want healthy in island nvlink-0", d)
+		}
+	}
+	want := apiv1.Device{
+		ID: 2, Health: apiv1.HealthHealthy, Bandwidth: 900, Island: "nvlink-0", PCIBusID: "0000:03:00.0",
+		UUID: "GPU-fake-2", Product: "Fake GPU", MemoryMiB: 81920, ComputeCapability: "9.0",
+		DriverVersion: "550.54.15", CUDAVersion: "12.4", MIG: "Disabled",
+	}
+	if !reflect.DeepEqual(devs[2], want) {
+		t.Errorf("device 2 = %+v, want %+v", devs[2], want)
+	}
+}
@@ -0,0 +1,52 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// Fake is a Discoverer returning a fixed set of GPUs, or Err.
+type Fake struct {
+	GPUs []GPU
+	Err  error
+}
+
+// NewFake returns count healthy GPUs all joined by NVLink, like one NVSwitch
+// baseboard, for clusters without GPUs.
+func NewFake(count int) *Fake {
+	f := &Fake{}
+	for i := 0; i < count; i++ {
+		links := map[int]Link{}
+		for peer := 0; peer < count; peer++ {
+			if peer != i {
+				links[peer] = Link{Type: "NV18", NVLinks: 18, GBps: 900}
+			}
+		}
+		f.GPUs = append(f.GPUs, GPU{
+			Index:      i,
+			UUID:       fmt.Sprintf("GPU-fake-%d", i),
+			Name:       "Fake GPU",
+			MemoryMiB:  81920,
+			PCIBusID:   fmt.Sprintf("0000:%02x:00.0", i+1),
+			Links:      links,
+			NVLinkGBps: 900,
+			Health:     apiv1.HealthHealthy,
+
+			ComputeCapability: "9.0",
+			DriverVersion:     "550.54.15",
+			CUDAVersion:       "12.4",
+			MIG:               "Disabled",
+		})
+	}
+	return f
+}
+
+// Discover returns a copy of the fake GPUs.
+func (f *Fake) Discover(context.Context) ([]GPU, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return append([]GPU(nil), f.GPUs...), nil
+}
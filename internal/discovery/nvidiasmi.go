@@ -0,0 +1,221 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// queryFields are the nvidia-smi --query-gpu fields read per GPU, in order.
+// compute_cap needs driver R510 or newer.
+var queryFields = []string{
+	"index", "uuid", "name", "memory.total", "pci.bus_id", "ecc.errors.uncorrected.volatile.total",
+	"compute_cap", "driver_version", "mig.mode.current",
+}
+
+// faultValues are values nvidia-smi reports for a field of a failed GPU.
+var faultValues = []string{"[GPU requires reset]", "[Unknown Error]", "[GPU is lost]"}
+
+var (
+	linkGPU     = regexp.MustCompile(`^GPU (\d+):`)
+	linkRate    = regexp.MustCompile(`^Link \d+: ([\d.]+) GB/s`)
+	cudaVersion = regexp.MustCompile(`(?m)^CUDA Version\s*:\s*(\S+)`)
+)
+
+// runFunc runs a command and returns its standard output.
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// NvidiaSMI discovers GPUs by running nvidia-smi, which the NVIDIA container
+// toolkit mounts into containers requesting the utility capability. It reads
+// what NVML reports without linking NVML, keeping the agent free of cgo, but
+// needs the binary on PATH.
+type NvidiaSMI struct {
+	// Path is the nvidia-smi binary.
+	Path string
+	run  runFunc
+}
+
+// NewNvidiaSMI returns a discoverer using nvidia-smi from PATH.
+func NewNvidiaSMI() *NvidiaSMI {
+	return &NvidiaSMI{Path: "nvidia-smi", run: runCommand}
+}
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Discover lists the GPUs with their health, links to every peer and NUMA node.
+func (n *NvidiaSMI) Discover(ctx context.Context) ([]GPU, error) {
+	out, err := n.run(ctx, n.Path, "--query-gpu="+strings.Join(queryFields, ","), "--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, err
+	}
+	gpus, err := parseQuery(out)
+	if err != nil || len(gpus) == 0 {
+		return gpus, err
+	}
+
+	// Drivers before R525 have no --version; the CUDA version stays unknown.
+	if out, err := n.run(ctx, n.Path, "--version"); err == nil {
+		if m := cudaVersion.FindSubmatch(out); m != nil {
+			for i := range gpus {
+				gpus[i].CUDAVersion = string(m[1])
+			}
+		}
+	}
+
+	out, err = n.run(ctx, n.Path, "topo", "-m")
+	if err != nil {
+		return nil, err
+	}
+	topo, err := parseTopo(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// nvlink -s reports link speeds; boards without NVLink have none to read.
+	rates := map[int]nvlinkRate{}
+	if topo.hasNVLink() {
+		out, err = n.run(ctx, n.Path, "nvlink", "-s")
+		if err != nil {
+			return nil, err
+		}
+		rates = parseNVLinkRates(out)
+	}
+
+	for i := range gpus {
+		g := &gpus[i]
+		rate := rates[g.Index]
+		g.Links = topo.links[g.Index]
+		for peer, l := range g.Links {
+			l.GBps = int(float64(l.NVLinks) * rate.perLink())
+			g.Links[peer] = l
+		}
+		g.NVLinkGBps = int(rate.total)
+		if node, ok := topo.numa[g.Index]; ok {
+			g.NUMANode = &node
+		}
+	}
+	return gpus, nil
+}
+
+// parseQuery reads the CSV output of nvidia-smi --query-gpu for queryFields.
+func parseQuery(out []byte) ([]GPU, error) {
+	var gpus []GPU
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, ",")
+		if len(f) != len(queryFields) {
+			return nil, fmt.Errorf("nvidia-smi query: want %d fields, got %q", len(queryFields), line)
+		}
+		for i := range f {
+			f[i] = strings.TrimSpace(f[i])
+		}
+		index, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("nvidia-smi query: bad index in %q", line)
+		}
+		mem, _ := strconv.Atoi(f[3])
+		gpus = append(gpus, GPU{
+			Index:     index,
+			UUID:      f[1],
+			Name:      f[2],
+			MemoryMiB: mem,
+			PCIBusID:  pciAddress(f[4]),
+			Health:    health(f),
+
+			ComputeCapability: notAvailable(f[6]),
+			DriverVersion:     notAvailable(f[7]),
+			MIG:               notAvailable(f[8]),
+		})
+	}
+	return gpus, sc.Err()
+}
+
+// notAvailable maps nvidia-smi's placeholder for unsupported fields to "".
+func notAvailable(v string) string {
+	if v == "[N/A]" {
+		return ""
+	}
+	return v
+}
+
+// pciAddress converts nvidia-smi's bus ID, which has an eight digit domain,
+// into the sysfs form.
+func pciAddress(busID string) string {
+	busID = strings.ToLower(busID)
+	if domain, rest, ok := strings.Cut(busID, ":"); ok && len(domain) > 4 {
+		return domain[len(domain)-4:] + ":" + rest
+	}
+	return busID
+}
+
+// health is Unhealthy when nvidia-smi reports a fault for any field or volatile
+// uncorrected ECC errors, and Healthy otherwise.
+func health(fields []string) string {
+	for _, v := range fields {
+		for _, fault := range faultValues {
+			if v == fault {
+				return apiv1.HealthUnhealthy
+			}
+		}
+	}
+	if ecc, err := strconv.Atoi(fields[5]); err == nil && ecc > 0 {
+		return apiv1.HealthUnhealthy
+	}
+	return apiv1.HealthHealthy
+}
+
+// nvlinkRate is the active NVLink bandwidth of one GPU.
+type nvlinkRate struct {
+	total float64
+	links int
+}
+
+// perLink is the average speed of one active link.
+func (r nvlinkRate) perLink() float64 {
+	if r.links == 0 {
+		return 0
+	}
+	return r.total / float64(r.links)
+}
+
+// parseNVLinkRates sums the active link speeds per GPU from nvidia-smi nvlink -s.
+func parseNVLinkRates(out []byte) map[int]nvlinkRate {
+	rates := map[int]nvlinkRate{}
+	gpu := -1
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if m := linkGPU.FindStringSubmatch(line); m != nil {
+			gpu, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := linkRate.FindStringSubmatch(line); m != nil && gpu >= 0 {
+			rate, _ := strconv.ParseFloat(m[1], 64)
+			r := rates[gpu]
+			r.total += rate
+			r.links++
+			rates[gpu] = r
+		}
+	}
+	return rates
+}
@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// dgxQuery is the --query-gpu output of the DGX A100 in testdata, with
+// GPU 2 reporting an uncorrected ECC error and GPU 3 needing a reset.
+const dgxQuery = `0, GPU-8a1c2e10-0c55-4d1d-8f73-2b1a7c4c8e00, NVIDIA A100-SXM4-80GB, 81920, 00000000:07:00.0, 0, 8.0, 535.129.03, Disabled
+1, GPU-8a1c2e11-0c55-4d1d-8f73-2b1a7c4c8e01, NVIDIA A100-SXM4-80GB, 81920, 00000000:0F:00.0, 0, 8.0, 535.129.03, Disabled
+2, GPU-8a1c2e12-0c55-4d1d-8f73-2b1a7c4c8e02, NVIDIA A100-SXM4-80GB, 81920, 00000000:47:00.0, 2, 8.0, 535.129.03, Disabled
+3, GPU-8a1c2e13-0c55-4d1d-8f73-2b1a7c4c8e03, NVIDIA A100-SXM4-80GB, [GPU requires reset], 00000000:4E:00.0, [GPU requires reset], [GPU requires reset], 535.129.03, [GPU requires reset]
+4, GPU-8a1c2e14-0c55-4d1d-8f73-2b1a7c4c8e04, NVIDIA A100-SXM4-80GB, 81920, 00000000:87:00.0, 0, 8.0, 535.129.03, Disabled
+5, GPU-8a1c2e15-0c55-4d1d-8f73-2b1a7c4c8e05, NVIDIA A100-SXM4-80GB, 81920, 00000000:90:00.0, 0, 8.0, 535.129.03, Disabled
+6, GPU-8a1c2e16-0c55-4d1d-8f73-2b1a7c4c8e06, NVIDIA A100-SXM4-80GB, 81920, 00000000:B7:00.0, 0, 8.0, 535.129.03, Disabled
+7, GPU-8a1c2e17-0c55-4d1d-8f73-2b1a7c4c8e07, NVIDIA A100-SXM4-80GB, 81920, 00000000:BD:00.0, 0, 8.0, 535.129.03, Disabled
+`
+
+const dgxVersion = `NVIDIA-SMI version  : 535.129.03
+NVML version        : 535.129
+DRIVER version      : 535.129.03
+CUDA Version        : 12.2
+`
+
+func readCapture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata/nvidia-smi", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// captured answers nvidia-smi invocations from canned output.
+func captured(outputs map[string]string) runFunc {
+	return func(_ context.Context, _ string, args ...string) ([]byte, error) {
+		key := strings.Join(args, " ")
+		if strings.HasPrefix(key, "--query-gpu") {
+			key = "query"
+		}
+		out, ok := outputs[key]
+		if !ok {
+			return nil, fmt.Errorf("unexpected nvidia-smi %s", strings.Join(args, " "))
+		}
+		return []byte(out), nil
+	}
+}
+
+func TestNvidiaSMIDiscover(t *testing.T) {
+	n := &NvidiaSMI{Path: "nvidia-smi", run: captured(map[string]string{
+		"query":     dgxQuery,
+		"topo -m":   readCapture(t, "dgx-a100-topo.txt"),
+		"nvlink -s": readCapture(t, "dgx-a100-nvlink.txt"),
+		"--version": dgxVersion,
+	})}
+	gpus, err := n.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpus) != 8 {
+		t.Fatalf("got %d GPUs, want 8", len(gpus))
+	}
+
+	nv12 := Link{Type: "NV12", NVLinks: 12, GBps: 300}
+	node := 3
+	want := GPU{
+		Index:      0,
+		UUID:       "GPU-8a1c2e10-0c55-4d1d-8f73-2b1a7c4c8e00",
+		Name:       "NVIDIA A100-SXM4-80GB",
+		MemoryMiB:  81920,
+		PCIBusID:   "0000:07:00.0",
+		NUMANode:   &node,
+		Links:      map[int]Link{1: nv12, 2: nv12, 3: nv12, 4: nv12, 5: nv12, 6: nv12, 7: nv12},
+		NVLinkGBps: 300,
+		Health:     apiv1.HealthHealthy,
+
+		ComputeCapability: "8.0",
+		DriverVersion:     "535.129.03",
+		CUDAVersion:       "12.2",
+		MIG:               "Disabled",
+	}
+	if !reflect.DeepEqual(gpus[0], want) {
+		t.Errorf("GPU 0 = %+v, want %+v", gpus[0], want)
+	}
+	for i, g := range gpus {
+		want := apiv1.HealthHealthy
+		if i == 2 || i == 3 {
+			want = apiv1.HealthUnhealthy
+		}
+		if g.Health != want {
+			t.Errorf("GPU %d health = %s, want %s", i, g.Health, want)
+		}
+	}
+}
+
+func TestNvidiaSMIDiscoverWithoutNVLink(t *testing.T) {
+	query := `0, GPU-1, NVIDIA L40S, 46068, 00000000:01:00.0, 0, 8.9, 550.54.15, [N/A]
+1, GPU-2, NVIDIA L40S, 46068, 00000000:02:00.0, 0, 8.9, 550.54.15, [N/A]
+2, GPU-3, NVIDIA L40S, 46068, 00000000:81:00.0, 0, 8.9, 550.54.15, [N/A]
+3, GPU-4, NVIDIA L40S, 46068, 00000000:82:00.0, 0, 8.9, 550.54.15, [N/A]
+`
+	// No nvlink -s answer: it must not be run on a board without NVLink.
+	n := &NvidiaSMI{run: captured(map[string]string{
+		"query":   query,
+		"topo -m": readCapture(t, "l40s-pcie-topo.txt"),
+	})}
+	gpus, err := n.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range Devices(gpus) {
+		if want := fmt.Sprintf("gpu-%d", i); d.Island != want || d.Bandwidth != 0 {
+			t.Errorf("device %d = %+v, want island %s without bandwidth", i, d, want)
+		}
+	}
+	if got := gpus[0].Links[1]; got != (Link{Type: LinkPXB}) {
+		t.Errorf("GPU 0 to 1 = %+v, want PXB", got)
+	}
+	// Without nvidia-smi --version the CUDA version is unknown; L40S has no MIG.
+	if g := gpus[0]; g.CUDAVersion != "" || g.MIG != "" || g.ComputeCapability != "8.9" {
+		t.Errorf("GPU 0 = %+v, want compute capability 8.9 without CUDA version or MIG", g)
+	}
+}
+
+func TestNvidiaSMIDiscoverErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		run  runFunc
+	}{
+		{name: "nvidia-smi missing", run: func(context.Context, string, ...string) ([]byte, error) {
+			return nil, errors.New("exec: \"nvidia-smi\": executable file not found in $PATH")
+		}},
+		{name: "malformed query", run: captured(map[string]string{"query": "0, GPU-x\n"})},
+		{name: "topology fails", run: captured(map[string]string{"query": dgxQuery})},
+		{name: "topology without matrix", run: captured(map[string]string{"query": dgxQuery, "topo -m": "No devices were found\n"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&NvidiaSMI{run: tt.run}).Discover(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// nvlinkMaxLinks is NVML_NVLINK_MAX_LINKS, the most NVLinks a GPU can have.
+const nvlinkMaxLinks = 18
+
+// nvlinkGBps is the per-direction speed of one link by NVLink version, as
+// nvidia-smi nvlink -s reports it.
+var nvlinkGBps = map[int]float64{1: 20, 2: 25.781, 3: 25, 4: 26.562, 5: 50}
+
+// topoLevels maps NVML's topology common ancestor levels onto topo -m codes.
+// Level 0 is two GPUs on one board.
+var topoLevels = map[int]string{0: LinkPIX, 10: LinkPIX, 20: LinkPXB, 30: LinkPHB, 40: LinkNODE, 50: LinkSYS}
+
+// nvmlError is an NVML return code other than success.
+type nvmlError int
+
+// NVML return codes the backend tells apart.
+const (
+	nvmlErrorInvalidArgument  nvmlError = 2
+	nvmlErrorNotSupported     nvmlError = 3
+	nvmlErrorNoPermission     nvmlError = 4
+	nvmlErrorDriverNotLoaded  nvmlError = 9
+	nvmlErrorFunctionNotFound nvmlError = 13
+	nvmlErrorGPUIsLost        nvmlError = 15
+	nvmlErrorResetRequired    nvmlError = 16
+	nvmlErrorUnknown          nvmlError = 999
+)
+
+var nvmlErrorText = map[nvmlError]string{
+	nvmlErrorInvalidArgument:  "invalid argument",
+	nvmlErrorNotSupported:     "not supported",
+	nvmlErrorNoPermission:     "insufficient permissions",
+	nvmlErrorDriverNotLoaded:  "driver not loaded",
+	nvmlErrorFunctionNotFound: "function not found",
+	nvmlErrorGPUIsLost:        "GPU is lost",
+	nvmlErrorResetRequired:    "GPU requires reset",
+	nvmlErrorUnknown:          "unknown error",
+}
+
+func (e nvmlError) Error() string {
+	if s, ok := nvmlErrorText[e]; ok {
+		return "NVML: " + s
+	}
+	return fmt.Sprintf("NVML: error %d", int(e))
+}
+
+// nvmlFault reports whether an NVML call failed because the GPU did, the
+// errors nvidia-smi shows as faultValues.
+func nvmlFault(err error) bool {
+	var e nvmlError
+	return errors.As(err, &e) && (e == nvmlErrorGPUIsLost || e == nvmlErrorResetRequired || e == nvmlErrorUnknown)
+}
+
+// nvmlLib is the part of an initialised NVML library the backend uses.
+type nvmlLib interface {
+	driverVersion() (string, error)
+	// cudaDriverVersion is the CUDA version the driver supports, e.g. 12040.
+	cudaDriverVersion() (int, error)
+	deviceCount() (int, error)
+	device(index int) (nvmlDevice, error)
+	shutdown() error
+}
+
+// nvmlDevice is one GPU handle.
+type nvmlDevice interface {
+	uuid() (string, error)
+	name() (string, error)
+	memoryTotal() (uint64, error)
+	// pciBusID is in NVML's form, e.g. 00000000:07:00.0.
+	pciBusID() (string, error)
+	computeCapability() (major, minor int, err error)
+	// migMode is the current MIG mode, 1 when enabled.
+	migMode() (int, error)
+	// eccUncorrected is the volatile count of uncorrected ECC errors.
+	eccUncorrected() (uint64, error)
+	nvlinkActive(link int) (bool, error)
+	nvlinkVersion(link int) (int, error)
+	// nvlinkRemoteBusID is the PCI address of the device across the link.
+	nvlinkRemoteBusID(link int) (string, error)
+	// commonAncestor is the topology level of the closest path to peer.
+	commonAncestor(peer nvmlDevice) (int, error)
+}
+
+// NVML discovers GPUs through the NVIDIA Management Library, which it loads
+// from the driver's libnvidia-ml.so.1 at run time. It reads what the
+// nvidia-smi backend does without needing the binary, but only in agents
+// built with cgo and the nvml build tag; other builds fail on Discover.
+type NVML struct {
+	// Root is the host filesystem root whose sysfs gives each GPU's PCIe
+	// root port and NUMA node.
+	Root string
+	open func() (nvmlLib, error)
+}
+
+// NewNVML returns an NVML discoverer reading sysfs under root.
+func NewNVML(root string) *NVML {
+	return &NVML{Root: root, open: openNVML}
+}
+
+// nvlinkPorts are the active NVLinks of one GPU.
+type nvlinkPorts struct {
+	active int
+	// rate is the speed of one link in GB/s.
+	rate float64
+	// peers counts the links to each directly attached GPU.
+	peers map[int]int
+	// switched counts the links to NVSwitches.
+	switched int
+}
+
+// Discover lists the GPUs with their health, links to every peer and NUMA node.
+// A GPU that NVML reports as lost or needing a reset is listed Unhealthy with
+// whatever it still reports.
+func (n *NVML) Discover(context.Context) ([]GPU, error) {
+	lib, err := n.open()
+	if err != nil {
+		return nil, err
+	}
+	defer lib.shutdown()
+
+	driver, _ := lib.driverVersion()
+	var cuda string
+	if v, err := lib.cudaDriverVersion(); err == nil && v > 0 {
+		cuda = fmt.Sprintf("%d.%d", v/1000, v%1000/10)
+	}
+	count, err := lib.deviceCount()
+	if err != nil {
+		return nil, fmt.Errorf("count GPUs: %w", err)
+	}
+
+	gpus := make([]GPU, count)
+	devs := make([]nvmlDevice, count)
+	for i := range gpus {
+		g := &gpus[i]
+		*g = GPU{Index: i, Health: apiv1.HealthHealthy, DriverVersion: driver, CUDAVersion: cuda}
+		d, err := lib.device(i)
+		if !nvmlOK(g, err) {
+			if g.Health != apiv1.HealthUnhealthy {
+				return nil, fmt.Errorf("get GPU %d: %w", i, err)
+			}
+			continue
+		}
+		devs[i] = d
+		n.describe(g, d)
+	}
+
+	byBus := make(map[string]int, count)
+	for _, g := range gpus {
+		if g.PCIBusID != "" {
+			byBus[g.PCIBusID] = g.Index
+		}
+	}
+	ports := make([]nvlinkPorts, count)
+	for i, d := range devs {
+		if d != nil {
+			ports[i] = nvlinks(&gpus[i], d, byBus)
+		}
+	}
+
+	for i := range gpus {
+		g := &gpus[i]
+		g.NVLinkGBps = int(float64(ports[i].active) * ports[i].rate)
+		g.Links = map[int]Link{}
+		for j := range gpus {
+			if j == i {
+				continue
+			}
+			// NVSwitch joins every pair of switched GPUs by the links
+			// they both have into the switch fabric.
+			links := ports[i].peers[j]
+			if ports[i].switched > 0 && ports[j].switched > 0 {
+				links += min(ports[i].switched, ports[j].switched)
+			}
+			switch {
+			case links > 0:
+				g.Links[j] = Link{Type: fmt.Sprintf("NV%d", links), NVLinks: links, GBps: int(float64(links) * ports[i].rate)}
+			case devs[i] != nil && devs[j] != nil:
+				g.Links[j] = Link{Type: LinkSYS}
+				if level, err := devs[i].commonAncestor(devs[j]); err == nil {
+					if typ, ok := topoLevels[level]; ok {
+						g.Links[j] = Link{Type: typ}
+					}
+				}
+			default:
+				g.Links[j] = Link{Type: LinkSYS}
+			}
+		}
+	}
+	return gpus, nil
+}
+
+// describe fills in the GPU's attributes and health. Attributes the GPU does
+// not support stay empty.
+func (n *NVML) describe(g *GPU, d nvmlDevice) {
+	if v, err := d.uuid(); nvmlOK(g, err) {
+		g.UUID = v
+	}
+	if v, err := d.name(); nvmlOK(g, err) {
+		g.Name = v
+	}
+	if v, err := d.memoryTotal(); nvmlOK(g, err) {
+		g.MemoryMiB = int(v >> 20)
+	}
+	if v, err := d.pciBusID(); nvmlOK(g, err) {
+		g.PCIBusID = pciAddress(v)
+		dev := filepath.Join(n.Root, "sys/bus/pci/devices", g.PCIBusID)
+		g.PCIeRoot, _ = pcieRoot(dev)
+		g.NUMANode = numaNode(dev)
+	}
+	if major, minor, err := d.computeCapability(); nvmlOK(g, err) {
+		g.ComputeCapability = fmt.Sprintf("%d.%d", major, minor)
+	}
+	if v, err := d.migMode(); nvmlOK(g, err) {
+		g.MIG = "Disabled"
+		if v == 1 {
+			g.MIG = "Enabled"
+		}
+	}
+	if v, err := d.eccUncorrected(); nvmlOK(g, err) && v > 0 {
+		g.Health = apiv1.HealthUnhealthy
+	}
+}
+
+// nvlinks reads the GPU's active NVLinks. A link whose remote end is not one
+// of the GPUs leads to an NVSwitch. NVML fails the first link past the ones
+// the GPU has, or every link on GPUs without NVLink.
+func nvlinks(g *GPU, d nvmlDevice, byBus map[string]int) nvlinkPorts {
+	p := nvlinkPorts{peers: map[int]int{}}
+	for link := 0; link < nvlinkMaxLinks; link++ {
+		up, err := d.nvlinkActive(link)
+		if !nvmlOK(g, err) {
+			break
+		}
+		if !up {
+			continue
+		}
+		p.active++
+		if v, err := d.nvlinkVersion(link); nvmlOK(g, err) {
+			p.rate = max(p.rate, nvlinkGBps[v])
+		}
+		remote, err := d.nvlinkRemoteBusID(link)
+		if !nvmlOK(g, err) {
+			continue
+		}
+		if peer, ok := byBus[pciAddress(remote)]; ok && peer != g.Index {
+			p.peers[peer]++
+		} else {
+			p.switched++
+		}
+	}
+	return p
+}
+
+// nvmlOK reports whether an NVML call on the GPU succeeded, marking the GPU
+// Unhealthy when it failed with a fault.
+func nvmlOK(g *GPU, err error) bool {
+	if nvmlFault(err) {
+		g.Health = apiv1.HealthUnhealthy
+	}
+	return err == nil
+}
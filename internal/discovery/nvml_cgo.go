@@ -0,0 +1,308 @@
+//go:build cgo && nvml
+
+package discovery
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stddef.h>
+
+// The subset of nvml.h the backend calls. Functions are resolved from the
+// driver's libnvidia-ml.so.1 at run time, so no NVML headers or stubs are
+// needed at build time and the agent starts on hosts without the driver.
+
+typedef int nvmlReturn_t;
+typedef struct nvmlDevice_st *nvmlDevice_t;
+
+typedef struct {
+	unsigned long long total;
+	unsigned long long free;
+	unsigned long long used;
+} nvmlMemory_t;
+
+typedef struct {
+	char busIdLegacy[16];
+	unsigned int domain;
+	unsigned int bus;
+	unsigned int device;
+	unsigned int pciDeviceId;
+	unsigned int pciSubSystemId;
+	char busId[32];
+} nvmlPciInfo_t;
+
+#define NVML_ERROR_FUNCTION_NOT_FOUND 13
+#define NVML_MEMORY_ERROR_TYPE_UNCORRECTED 1
+#define NVML_VOLATILE_ECC 0
+
+static void *nvml;
+static nvmlReturn_t (*p_nvmlInit_v2)(void);
+static nvmlReturn_t (*p_nvmlShutdown)(void);
+static nvmlReturn_t (*p_nvmlSystemGetDriverVersion)(char *, unsigned int);
+static nvmlReturn_t (*p_nvmlSystemGetCudaDriverVersion)(int *);
+static nvmlReturn_t (*p_nvmlDeviceGetCount_v2)(unsigned int *);
+static nvmlReturn_t (*p_nvmlDeviceGetHandleByIndex_v2)(unsigned int, nvmlDevice_t *);
+static nvmlReturn_t (*p_nvmlDeviceGetUUID)(nvmlDevice_t, char *, unsigned int);
+static nvmlReturn_t (*p_nvmlDeviceGetName)(nvmlDevice_t, char *, unsigned int);
+static nvmlReturn_t (*p_nvmlDeviceGetMemoryInfo)(nvmlDevice_t, nvmlMemory_t *);
+static nvmlReturn_t (*p_nvmlDeviceGetPciInfo_v3)(nvmlDevice_t, nvmlPciInfo_t *);
+static nvmlReturn_t (*p_nvmlDeviceGetCudaComputeCapability)(nvmlDevice_t, int *, int *);
+static nvmlReturn_t (*p_nvmlDeviceGetMigMode)(nvmlDevice_t, unsigned int *, unsigned int *);
+static nvmlReturn_t (*p_nvmlDeviceGetTotalEccErrors)(nvmlDevice_t, int, int, unsigned long long *);
+static nvmlReturn_t (*p_nvmlDeviceGetNvLinkState)(nvmlDevice_t, unsigned int, int *);
+static nvmlReturn_t (*p_nvmlDeviceGetNvLinkVersion)(nvmlDevice_t, unsigned int, unsigned int *);
+static nvmlReturn_t (*p_nvmlDeviceGetNvLinkRemotePciInfo_v2)(nvmlDevice_t, unsigned int, nvmlPciInfo_t *);
+static nvmlReturn_t (*p_nvmlDeviceGetTopologyCommonAncestor)(nvmlDevice_t, nvmlDevice_t, int *);
+
+#define LOAD(fn) (p_##fn = (typeof(p_##fn))dlsym(nvml, #fn))
+#define CALL(fn, ...) (p_##fn ? p_##fn(__VA_ARGS__) : NVML_ERROR_FUNCTION_NOT_FOUND)
+
+// nvml_load opens the library once and resolves its functions, returning
+// the loader's error or NULL. Functions missing from older drivers stay NULL
+// and fail with NVML_ERROR_FUNCTION_NOT_FOUND.
+static const char *nvml_load(void) {
+	if (nvml)
+		return NULL;
+	nvml = dlopen("libnvidia-ml.so.1", RTLD_LAZY | RTLD_GLOBAL);
+	if (!nvml)
+		return dlerror();
+	LOAD(nvmlInit_v2);
+	LOAD(nvmlShutdown);
+	LOAD(nvmlSystemGetDriverVersion);
+	LOAD(nvmlSystemGetCudaDriverVersion);
+	LOAD(nvmlDeviceGetCount_v2);
+	LOAD(nvmlDeviceGetHandleByIndex_v2);
+	LOAD(nvmlDeviceGetUUID);
+	LOAD(nvmlDeviceGetName);
+	LOAD(nvmlDeviceGetMemoryInfo);
+	LOAD(nvmlDeviceGetPciInfo_v3);
+	LOAD(nvmlDeviceGetCudaComputeCapability);
+	LOAD(nvmlDeviceGetMigMode);
+	LOAD(nvmlDeviceGetTotalEccErrors);
+	LOAD(nvmlDeviceGetNvLinkState);
+	LOAD(nvmlDeviceGetNvLinkVersion);
+	LOAD(nvmlDeviceGetNvLinkRemotePciInfo_v2);
+	LOAD(nvmlDeviceGetTopologyCommonAncestor);
+	return NULL;
+}
+
+static nvmlReturn_t nvml_init(void) {
+	return p_nvmlInit_v2 ? p_nvmlInit_v2() : NVML_ERROR_FUNCTION_NOT_FOUND;
+}
+
+static nvmlReturn_t nvml_shutdown(void) {
+	return p_nvmlShutdown ? p_nvmlShutdown() : NVML_ERROR_FUNCTION_NOT_FOUND;
+}
+
+static nvmlReturn_t nvml_driver_version(char *buf, unsigned int n) {
+	return CALL(nvmlSystemGetDriverVersion, buf, n);
+}
+
+static nvmlReturn_t nvml_cuda_driver_version(int *v) {
+	return CALL(nvmlSystemGetCudaDriverVersion, v);
+}
+
+static nvmlReturn_t nvml_device_count(unsigned int *n) {
+	return CALL(nvmlDeviceGetCount_v2, n);
+}
+
+static nvmlReturn_t nvml_device(unsigned int i, nvmlDevice_t *d) {
+	return CALL(nvmlDeviceGetHandleByIndex_v2, i, d);
+}
+
+static nvmlReturn_t nvml_uuid(nvmlDevice_t d, char *buf, unsigned int n) {
+	return CALL(nvmlDeviceGetUUID, d, buf, n);
+}
+
+static nvmlReturn_t nvml_name(nvmlDevice_t d, char *buf, unsigned int n) {
+	return CALL(nvmlDeviceGetName, d, buf, n);
+}
+
+static nvmlReturn_t nvml_memory_total(nvmlDevice_t d, unsigned long long *total) {
+	nvmlMemory_t m = {0};
+	nvmlReturn_t r = CALL(nvmlDeviceGetMemoryInfo, d, &m);
+	*total = m.total;
+	return r;
+}
+
+static nvmlReturn_t nvml_pci_bus_id(nvmlDevice_t d, nvmlPciInfo_t *pci) {
+	return CALL(nvmlDeviceGetPciInfo_v3, d, pci);
+}
+
+static nvmlReturn_t nvml_compute_capability(nvmlDevice_t d, int *major, int *minor) {
+	return CALL(nvmlDeviceGetCudaComputeCapability, d, major, minor);
+}
+
+static nvmlReturn_t nvml_mig_mode(nvmlDevice_t d, unsigned int *current) {
+	unsigned int pending;
+	return CALL(nvmlDeviceGetMigMode, d, current, &pending);
+}
+
+static nvmlReturn_t nvml_ecc_uncorrected(nvmlDevice_t d, unsigned long long *count) {
+	return CALL(nvmlDeviceGetTotalEccErrors, d, NVML_MEMORY_ERROR_TYPE_UNCORRECTED, NVML_VOLATILE_ECC, count);
+}
+
+static nvmlReturn_t nvml_nvlink_state(nvmlDevice_t d, unsigned int link, int *active) {
+	return CALL(nvmlDeviceGetNvLinkState, d, link, active);
+}
+
+static nvmlReturn_t nvml_nvlink_version(nvmlDevice_t d, unsigned int link, unsigned int *v) {
+	return CALL(nvmlDeviceGetNvLinkVersion, d, link, v);
+}
+
+static nvmlReturn_t nvml_nvlink_remote(nvmlDevice_t d, unsigned int link, nvmlPciInfo_t *pci) {
+	return CALL(nvmlDeviceGetNvLinkRemotePciInfo_v2, d, link, pci);
+}
+
+static nvmlReturn_t nvml_common_ancestor(nvmlDevice_t a, nvmlDevice_t b, int *level) {
+	return CALL(nvmlDeviceGetTopologyCommonAncestor, a, b, level);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+)
+
+// nvmlStringLen fits NVML's longest strings, NVML_DEVICE_NAME_V2_BUFFER_SIZE.
+const nvmlStringLen = 96
+
+// nvmlLoad serialises loading the library; dlopen'd once, it stays loaded.
+var nvmlLoad sync.Mutex
+
+func nvmlErr(r C.nvmlReturn_t) error {
+	if r == 0 {
+		return nil
+	}
+	return nvmlError(r)
+}
+
+// openNVML loads libnvidia-ml.so.1 and initialises NVML.
+func openNVML() (nvmlLib, error) {
+	nvmlLoad.Lock()
+	msg := C.nvml_load()
+	nvmlLoad.Unlock()
+	if msg != nil {
+		return nil, fmt.Errorf("load NVML: %s", C.GoString(msg))
+	}
+	if err := nvmlErr(C.nvml_init()); err != nil {
+		return nil, fmt.Errorf("initialise NVML: %w", err)
+	}
+	return cgoLib{}, nil
+}
+
+type cgoLib struct{}
+
+func (cgoLib) driverVersion() (string, error) {
+	var buf [nvmlStringLen]C.char
+	if err := nvmlErr(C.nvml_driver_version(&buf[0], nvmlStringLen)); err != nil {
+		return "", err
+	}
+	return C.GoString(&buf[0]), nil
+}
+
+func (cgoLib) cudaDriverVersion() (int, error) {
+	var v C.int
+	err := nvmlErr(C.nvml_cuda_driver_version(&v))
+	return int(v), err
+}
+
+func (cgoLib) deviceCount() (int, error) {
+	var n C.uint
+	err := nvmlErr(C.nvml_device_count(&n))
+	return int(n), err
+}
+
+func (cgoLib) device(index int) (nvmlDevice, error) {
+	var d C.nvmlDevice_t
+	if err := nvmlErr(C.nvml_device(C.uint(index), &d)); err != nil {
+		return nil, err
+	}
+	return cgoDevice{d}, nil
+}
+
+func (cgoLib) shutdown() error {
+	return nvmlErr(C.nvml_shutdown())
+}
+
+type cgoDevice struct {
+	h C.nvmlDevice_t
+}
+
+func (d cgoDevice) uuid() (string, error) {
+	var buf [nvmlStringLen]C.char
+	if err := nvmlErr(C.nvml_uuid(d.h, &buf[0], nvmlStringLen)); err != nil {
+		return "", err
+	}
+	return C.GoString(&buf[0]), nil
+}
+
+func (d cgoDevice) name() (string, error) {
+	var buf [nvmlStringLen]C.char
+	if err := nvmlErr(C.nvml_name(d.h, &buf[0], nvmlStringLen)); err != nil {
+		return "", err
+	}
+	return C.GoString(&buf[0]), nil
+}
+
+func (d cgoDevice) memoryTotal() (uint64, error) {
+	var total C.ulonglong
+	err := nvmlErr(C.nvml_memory_total(d.h, &total))
+	return uint64(total), err
+}
+
+func (d cgoDevice) pciBusID() (string, error) {
+	var pci C.nvmlPciInfo_t
+	if err := nvmlErr(C.nvml_pci_bus_id(d.h, &pci)); err != nil {
+		return "", err
+	}
+	return C.GoString(&pci.busId[0]), nil
+}
+
+func (d cgoDevice) computeCapability() (int, int, error) {
+	var major, minor C.int
+	err := nvmlErr(C.nvml_compute_capability(d.h, &major, &minor))
+	return int(major), int(minor), err
+}
+
+func (d cgoDevice) migMode() (int, error) {
+	var mode C.uint
+	err := nvmlErr(C.nvml_mig_mode(d.h, &mode))
+	return int(mode), err
+}
+
+func (d cgoDevice) eccUncorrected() (uint64, error) {
+	var count C.ulonglong
+	err := nvmlErr(C.nvml_ecc_uncorrected(d.h, &count))
+	return uint64(count), err
+}
+
+func (d cgoDevice) nvlinkActive(link int) (bool, error) {
+	var active C.int
+	err := nvmlErr(C.nvml_nvlink_state(d.h, C.uint(link), &active))
+	return active != 0, err
+}
+
+func (d cgoDevice) nvlinkVersion(link int) (int, error) {
+	var v C.uint
+	err := nvmlErr(C.nvml_nvlink_version(d.h, C.uint(link), &v))
+	return int(v), err
+}
+
+func (d cgoDevice) nvlinkRemoteBusID(link int) (string, error) {
+	var pci C.nvmlPciInfo_t
+	if err := nvmlErr(C.nvml_nvlink_remote(d.h, C.uint(link), &pci)); err != nil {
+		return "", err
+	}
+	return C.GoString(&pci.busId[0]), nil
+}
+
+func (d cgoDevice) commonAncestor(peer nvmlDevice) (int, error) {
+	p, ok := peer.(cgoDevice)
+	if !ok {
+		return 0, nvmlErrorInvalidArgument
+	}
+	var level C.int
+	err := nvmlErr(C.nvml_common_ancestor(d.h, p.h, &level))
+	return int(level), err
+}
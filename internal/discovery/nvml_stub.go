@@ -0,0 +1,10 @@
+//go:build !(cgo && nvml)
+
+package discovery
+
+import "errors"
+
+// openNVML fails in agents built without NVML; loading libnvidia-ml needs cgo.
+func openNVML() (nvmlLib, error) {
+	return nil, errors.New("agent built without NVML support; rebuild with CGO_ENABLED=1 -tags nvml")
+}
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/topo"
+)
+
+// fakeNVML is an NVML library answering from fixed devices.
+type fakeNVML struct {
+	devs     []*fakeDevice
+	countErr error
+	closed   bool
+}
+
+func (f *fakeNVML) driverVersion() (string, error)  { return "535.129.03", nil }
+func (f *fakeNVML) cudaDriverVersion() (int, error) { return 12020, nil }
+func (f *fakeNVML) shutdown() error                 { f.closed = true; return nil }
+
+func (f *fakeNVML) open() (nvmlLib, error) { return f, nil }
+
+func (f *fakeNVML) deviceCount() (int, error) { return len(f.devs), f.countErr }
+
+func (f *fakeNVML) device(i int) (nvmlDevice, error) {
+	if err := f.devs[i].handleErr; err != nil {
+		return nil, err
+	}
+	return f.devs[i], nil
+}
+
+// fakeDevice is a GPU whose NVLinks lead to the PCI addresses in remotes,
+// all at version.
+type fakeDevice struct {
+	busID     string
+	ecc       uint64
+	handleErr error
+	memErr    error
+	remotes   []string
+	version   int
+	// ancestors maps a peer's bus ID to the topology level between them.
+	ancestors map[string]int
+}
+
+func (d *fakeDevice) uuid() (string, error)           { return "GPU-" + d.busID, nil }
+func (d *fakeDevice) name() (string, error)           { return "NVIDIA H100 80GB HBM3", nil }
+func (d *fakeDevice) pciBusID() (string, error)       { return d.busID, nil }
+func (d *fakeDevice) migMode() (int, error)           { return 0, nil }
+func (d *fakeDevice) eccUncorrected() (uint64, error) { return d.ecc, nil }
+
+func (d *fakeDevice) memoryTotal() (uint64, error) { return 80 << 30, d.memErr }
+
+func (d *fakeDevice) computeCapability() (int, int, error) { return 9, 0, nil }
+
+func (d *fakeDevice) nvlinkActive(link int) (bool, error) {
+	if len(d.remotes) == 0 {
+		return false, nvmlErrorNotSupported
+	}
+	if link >= len(d.remotes) {
+		return false, nvmlErrorInvalidArgument
+	}
+	return true, nil
+}
+
+func (d *fakeDevice) nvlinkVersion(int) (int, error) { return d.version, nil }
+
+func (d *fakeDevice) nvlinkRemoteBusID(link int) (string, error) { return d.remotes[link], nil }
+
+func (d *fakeDevice) commonAncestor(peer nvmlDevice) (int, error) {
+	level, ok := d.ancestors[peer.(*fakeDevice).busID]
+	if !ok {
+		return 0, nvmlErrorNotSupported
+	}
+	return level, nil
+}
+
+// Bus IDs of the GPUs in testdata/sysfs-hgx, in NVML's form.
+const (
+	hgxGPU0 = "00000000:18:00.0"
+	hgxGPU1 = "00000000:2A:00.0"
+	hgxGPU2 = "00000000:9A:00.0"
+	hgxGPU3 = "00000000:AB:00.0"
+)
+
+// nvlinksTo is n NVLinks leading to one remote device.
+func nvlinksTo(n int, remote string) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = remote
+	}
+	return out
+}
+
+// An HGX H100 board: every GPU runs its 18 NVLinks into the NVSwitches. GPU 2
+// reports an uncorrected ECC error and GPU 3 is lost.
+func TestNVMLDiscoverNVSwitch(t *testing.T) {
+	nvswitch := "00000000:C0:00.0"
+	lib := &fakeNVML{}
+	for _, bus := range []string{hgxGPU0, hgxGPU1, hgxGPU2, hgxGPU3} {
+		lib.devs = append(lib.devs, &fakeDevice{busID: bus, remotes: nvlinksTo(18, nvswitch), version: 4})
+	}
+	lib.devs[2].ecc = 1
+	lib.devs[3].memErr = nvmlErrorGPUIsLost
+
+	n := &NVML{Root: "testdata/sysfs-hgx", open: lib.open}
+	gpus, err := n.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lib.closed {
+		t.Error("NVML was not shut down")
+	}
+	if len(gpus) != 4 {
+		t.Fatalf("got %d GPUs, want 4", len(gpus))
+	}
+
+	nv18 := Link{Type: "NV18", NVLinks: 18, GBps: 478}
+	node := 0
+	want := GPU{
+		Index:      0,
+		UUID:       "GPU-" + hgxGPU0,
+		Name:       "NVIDIA H100 80GB HBM3",
+		MemoryMiB:  81920,
+		PCIBusID:   "0000:18:00.0",
+		PCIeRoot:   "0000:00:01.0",
+		NUMANode:   &node,
+		Links:      map[int]Link{1: nv18, 2: nv18, 3: nv18},
+		NVLinkGBps: 478,
+		Health:     apiv1.HealthHealthy,
+
+		ComputeCapability: "9.0",
+		DriverVersion:     "535.129.03",
+		CUDAVersion:       "12.2",
+		MIG:               "Disabled",
+	}
+	if !reflect.DeepEqual(gpus[0], want) {
+		t.Errorf("GPU 0 = %+v, want %+v", gpus[0], want)
+	}
+	for i, g := range gpus {
+		want := apiv1.HealthHealthy
+		if i == 2 || i == 3 {
+			want = apiv1.HealthUnhealthy
+		}
+		if g.Health != want {
+			t.Errorf("GPU %d health = %s, want %s", i, g.Health, want)
+		}
+	}
+	for _, l := range Links(gpus) {
+		if l.Type != string(topo.LinkNVSwitch) {
+			t.Errorf("link %+v, want NVSwitch", l)
+		}
+	}
+}
+
+// Two GPUs joined by an NVLink bridge and two on PCIe only, across sockets.
+func TestNVMLDiscoverBridged(t *testing.T) {
+	lib := &fakeNVML{devs: []*fakeDevice{
+		{busID: hgxGPU0, remotes: nvlinksTo(4, hgxGPU1), version: 3, ancestors: map[string]int{hgxGPU2: 50, hgxGPU3: 50}},
+		{busID: hgxGPU1, remotes: nvlinksTo(4, hgxGPU0), version: 3, ancestors: map[string]int{hgxGPU2: 50, hgxGPU3: 50}},
+		{busID: hgxGPU2, ancestors: map[string]int{hgxGPU0: 50, hgxGPU1: 50, hgxGPU3: 20}},
+		{busID: hgxGPU3, ancestors: map[string]int{hgxGPU0: 50, hgxGPU1: 50}},
+	}}
+	gpus, err := (&NVML{Root: "testdata/sysfs-hgx", open: lib.open}).Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nv4, pxb, sys := Link{Type: "NV4", NVLinks: 4, GBps: 100}, Link{Type: LinkPXB}, Link{Type: LinkSYS}
+	want := []map[int]Link{
+		{1: nv4, 2: sys, 3: sys},
+		{0: nv4, 2: sys, 3: sys},
+		{0: sys, 1: sys, 3: pxb},
+		// NVML has no ancestor from GPU 3 to GPU 2; the path is assumed SYS.
+		{0: sys, 1: sys, 2: sys},
+	}
+	for i, g := range gpus {
+		if !reflect.DeepEqual(g.Links, want[i]) {
+			t.Errorf("GPU %d links = %+v, want %+v", i, g.Links, want[i])
+		}
+	}
+	if gpus[0].NVLinkGBps != 100 || gpus[2].NVLinkGBps != 0 {
+		t.Errorf("NVLink bandwidth = %d, %d, want 100, 0", gpus[0].NVLinkGBps, gpus[2].NVLinkGBps)
+	}
+	for i, d := range Devices(gpus) {
+		want := map[int]string{0: "nvlink-0", 1: "nvlink-0", 2: "gpu-2", 3: "gpu-3"}[i]
+		if d.Island != want {
+			t.Errorf("device %d island = %s, want %s", i, d.Island, want)
+		}
+	}
+}
+
+// A GPU too broken to return a handle is listed Unhealthy rather than
+// failing discovery of its peers.
+func TestNVMLDiscoverLostHandle(t *testing.T) {
+	lib := &fakeNVML{devs: []*fakeDevice{
+		{busID: hgxGPU0, ancestors: map[string]int{}},
+		{handleErr: nvmlErrorResetRequired},
+	}}
+	gpus, err := (&NVML{open: lib.open}).Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := gpus[1]; g.Health != apiv1.HealthUnhealthy || g.UUID != "" || g.Links[0] != (Link{Type: LinkSYS}) {
+		t.Errorf("GPU 1 = %+v, want Unhealthy without UUID", g)
+	}
+	if gpus[0].Health != apiv1.HealthHealthy {
+		t.Errorf("GPU 0 health = %s, want Healthy", gpus[0].Health)
+	}
+}
+
+func TestNVMLDiscoverErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		open func() (nvmlLib, error)
+	}{
+		{name: "library missing", open: func() (nvmlLib, error) {
+			return nil, errors.New("load NVML: libnvidia-ml.so.1: cannot open shared object file")
+		}},
+		{name: "count fails", open: (&fakeNVML{countErr: nvmlErrorDriverNotLoaded}).open},
+		{name: "handle fails", open: (&fakeNVML{devs: []*fakeDevice{{handleErr: nvmlErrorNoPermission}}}).open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&NVML{open: tt.open}).Discover(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNVMLError(t *testing.T) {
+	if got := nvmlErrorGPUIsLost.Error(); got != "NVML: GPU is lost" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := nvmlError(26).Error(); got != "NVML: error 26" {
+		t.Errorf("Error() = %q", got)
+	}
+	if nvmlFault(nvmlErrorNotSupported) || !nvmlFault(errors.Join(errors.New("read"), nvmlErrorResetRequired)) {
+		t.Error("nvmlFault misclassified")
+	}
+}
@@ -0,0 +1,171 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// gpuDrivers are the kernel drivers of GPUs usable by containers, by PCI vendor ID.
+var gpuDrivers = map[string]string{
+	"0x10de": "nvidia",
+	"0x1002": "amdgpu",
+}
+
+// pciClassDisplay is the PCI base class of display and 3D controllers.
+const pciClassDisplay = "0x03"
+
+// Sysfs discovers GPUs from the PCI devices in sysfs and the NVIDIA driver's
+// procfs entries, for hosts where NVML is not available in the agent. It sees
+// no NVLink or health signals: each GPU is its own island with Unknown health,
+// linked to its peers by PCIe root port and NUMA node. GPUs the driver
+// excludes are hidden from NVML and CUDA, so they are skipped.
+type Sysfs struct {
+	// Root is the host filesystem root holding sys/ and proc/.
+	Root string
+}
+
+// NewSysfs returns a Sysfs discoverer reading the host filesystem under root.
+func NewSysfs(root string) *Sysfs {
+	return &Sysfs{Root: root}
+}
+
+// Discover lists the GPUs bound to a GPU driver. They are indexed in PCI bus
+// order, which is how nvidia-smi and CUDA_DEVICE_ORDER=PCI_BUS_ID number them;
+// excluded GPUs take no index, as in that numbering.
+func (s *Sysfs) Discover(context.Context) ([]GPU, error) {
+	dir := filepath.Join(s.Root, "sys/bus/pci/devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list PCI devices: %w", err)
+	}
+	var gpus []GPU
+	for _, e := range entries {
+		addr := e.Name()
+		dev := filepath.Join(dir, addr)
+		vendor := readAttr(dev, "vendor")
+		driver, ok := gpuDrivers[vendor]
+		if !ok || !strings.HasPrefix(readAttr(dev, "class"), pciClassDisplay) {
+			continue
+		}
+		if link, err := os.Readlink(filepath.Join(dev, "driver")); err != nil || filepath.Base(link) != driver {
+			continue
+		}
+		info := nvidiaInfo(filepath.Join(s.Root, "proc/driver/nvidia/gpus", addr, "information"))
+		if info["GPU Excluded"] == "Yes" {
+			continue
+		}
+		root, err := pcieRoot(dev)
+		if err != nil {
+			return nil, err
+		}
+
+		g := GPU{
+			Index:    len(gpus),
+			Name:     readAttr(dev, "product_name"),
+			PCIBusID: addr,
+			PCIeRoot: root,
+			NUMANode: numaNode(dev),
+			Health:   apiv1.HealthUnknown,
+		}
+		if vram, err := strconv.ParseInt(readAttr(dev, "mem_info_vram_total"), 10, 64); err == nil {
+			g.MemoryMiB = int(vram >> 20)
+		}
+		g.DriverVersion = readAttr(filepath.Join(s.Root, "sys/module", driver), "version")
+		if info != nil {
+			g.Name = info["Model"]
+			g.UUID = info["GPU UUID"]
+		}
+		if g.Name == "" {
+			g.Name = vendor + ":" + readAttr(dev, "device")
+		}
+		gpus = append(gpus, g)
+	}
+	pcieLinks(gpus)
+	return gpus, nil
+}
+
+// pcieLinks joins GPUs under one root port by PCIe switch, GPUs on one NUMA
+// node through the host, and all others across the system.
+func pcieLinks(gpus []GPU) {
+	for i := range gpus {
+		a := &gpus[i]
+		a.Links = map[int]Link{}
+		for _, b := range gpus {
+			switch {
+			case b.Index == a.Index:
+				continue
+			case a.PCIeRoot != "" && a.PCIeRoot == b.PCIeRoot:
+				a.Links[b.Index] = Link{Type: LinkPXB}
+			case a.NUMANode != nil && b.NUMANode != nil && *a.NUMANode == *b.NUMANode:
+				a.Links[b.Index] = Link{Type: LinkNODE}
+			default:
+				a.Links[b.Index] = Link{Type: LinkSYS}
+			}
+		}
+	}
+}
+
+// readAttr returns a sysfs attribute with surrounding whitespace removed, or
+// "" when it cannot be read.
+func readAttr(dev, name string) string {
+	b, err := os.ReadFile(filepath.Join(dev, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// pcieRoot is the root port above a PCI device: the first device below the
+// host bridge (pciDDDD:BB) in its resolved sysfs path. A device on the root
+// complex itself reports the host bridge.
+func pcieRoot(dev string) (string, error) {
+	path, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", fmt.Errorf("resolve PCI device: %w", err)
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i, p := range parts {
+		if !strings.HasPrefix(p, "pci") {
+			continue
+		}
+		if i+2 < len(parts) {
+			return parts[i+1], nil
+		}
+		return strings.TrimPrefix(p, "pci"), nil
+	}
+	return "", nil
+}
+
+// numaNode reads the device's NUMA node; the kernel reports -1 without NUMA.
+func numaNode(dev string) *int {
+	n, err := strconv.Atoi(readAttr(dev, "numa_node"))
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// nvidiaInfo parses the "Key: value" lines of the NVIDIA driver's per-GPU
+// information file, or returns nil when there is none.
+func nvidiaInfo(path string) map[string]string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	info := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), ":"); ok {
+			info[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return info
+}
@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// testdata/sysfs-hgx is a two socket host: GPUs 0 and 1 behind a PCIe switch
+// on socket 0, GPUs 2 and 3 on socket 1. A GPU excluded by the driver sits
+// between GPUs 1 and 2 and must take no index. A NIC, an NVSwitch, a GPU bound
+// to vfio-pci and the bridges must be skipped.
+func TestSysfsDiscover(t *testing.T) {
+	gpus, err := NewSysfs("testdata/sysfs-hgx").Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := func(n int) *int { return &n }
+	pxb, sys := Link{Type: LinkPXB}, Link{Type: LinkSYS}
+	want := []GPU{
+		{Index: 0, UUID: "GPU-2b9d5c3e-1f1a-4f0e-9d4a-5c1e7f0b3a10", Name: "NVIDIA H100 80GB HBM3", PCIBusID: "0000:18:00.0", PCIeRoot: "0000:00:01.0", NUMANode: node(0), Links: map[int]Link{1: pxb, 2: sys, 3: sys}, Health: apiv1.HealthUnknown, DriverVersion: "535.129.03"},
+		{Index: 1, UUID: "GPU-7c1e0a4f-3d2b-4e5c-8f6a-9b0c1d2e3f21", Name: "NVIDIA H100 80GB HBM3", PCIBusID: "0000:2a:00.0", PCIeRoot: "0000:00:01.0", NUMANode: node(0), Links: map[int]Link{0: pxb, 2: sys, 3: sys}, Health: apiv1.HealthUnknown, DriverVersion: "535.129.03"},
+		{Index: 2, UUID: "GPU-c4d5e6f7-0a1b-4c2d-9e3f-4a5b6c7d8e32", Name: "NVIDIA H100 80GB HBM3", PCIBusID: "0000:9a:00.0", PCIeRoot: "0000:80:01.0", NUMANode: node(1), Links: map[int]Link{0: sys, 1: sys, 3: pxb}, Health: apiv1.HealthUnknown, DriverVersion: "535.129.03"},
+		{Index: 3, UUID: "GPU-0f1e2d3c-4b5a-4968-8776-a5b4c3d2e143", Name: "NVIDIA H100 80GB HBM3", PCIBusID: "0000:ab:00.0", PCIeRoot: "0000:80:01.0", NUMANode: node(1), Links: map[int]Link{0: sys, 1: sys, 2: pxb}, Health: apiv1.HealthUnknown, DriverVersion: "535.129.03"},
+	}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Fatalf("Discover =\n%+v\nwant\n%+v", gpus, want)
+	}
+
+	devs := Devices(gpus)
+	if got := devs[1]; got.Island != "gpu-1" || got.PCIeRoot != "0000:00:01.0" || got.PCIBusID != "0000:2a:00.0" || *got.NUMANode != 0 {
+		t.Errorf("device 1 = %+v", got)
+	}
+	if got := devs[3]; got.ID != 3 || got.PCIBusID != "0000:ab:00.0" {
+		t.Errorf("device 3 = %+v, want ID 3 at 0000:ab:00.0 after the excluded GPU", got)
+	}
+}
+
+func TestSysfsDiscoverMissingRoot(t *testing.T) {
+	if _, err := NewSysfs(t.TempDir()).Discover(context.Background()); err == nil {
+		t.Error("expected an error without sys/bus/pci/devices")
+	}
+}
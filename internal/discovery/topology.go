@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PCIe path types in the nvidia-smi topo -m matrix, nearest first. NVLink
+// connections are reported as NV<n> for n bonded links.
+const (
+	LinkPIX  = "PIX"  // at most one PCIe bridge
+	LinkPXB  = "PXB"  // multiple PCIe bridges, no host bridge
+	LinkPHB  = "PHB"  // a PCIe host bridge
+	LinkNODE = "NODE" // host bridges within one NUMA node
+	LinkSYS  = "SYS"  // the SMP interconnect between NUMA nodes
+)
+
+// Link is a GPU's connection to one peer.
+type Link struct {
+	// Type is the nvidia-smi topo code: NV<n>, PIX, PXB, PHB, NODE or SYS.
+	Type string
+	// NVLinks is the number of bonded NVLinks, 0 for PCIe paths.
+	NVLinks int
+	// GBps is the NVLink bandwidth to the peer, 0 for PCIe paths.
+	GBps int
+}
+
+var (
+	ansi    = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	gpuName = regexp.MustCompile(`^GPU(\d+)$`)
+	nvCell  = regexp.MustCompile(`^NV(\d+)$`)
+)
+
+// topoMatrix is the GPU part of the nvidia-smi topo -m matrix.
+type topoMatrix struct {
+	// links maps each GPU to its peers.
+	links map[int]map[int]Link
+	// numa holds the NUMA affinity of GPUs that report one.
+	numa map[int]int
+}
+
+func (t topoMatrix) hasNVLink() bool {
+	for _, peers := range t.links {
+		for _, l := range peers {
+			if l.NVLinks > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseTopo reads nvidia-smi topo -m. The matrix is tab separated: a header
+// naming one column per GPU, NIC and affinity, then one row per device. Rows
+// of NICs and the legend below the matrix are ignored.
+func parseTopo(out []byte) (topoMatrix, error) {
+	t := topoMatrix{links: map[int]map[int]Link{}, numa: map[int]int{}}
+	var header []string
+	for _, line := range strings.Split(string(ansi.ReplaceAll(out, nil)), "\n") {
+		cells := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		if header == nil {
+			if len(cells) > 1 && cells[0] == "" && gpuName.MatchString(cells[1]) {
+				header = cells
+			}
+			continue
+		}
+		m := gpuName.FindStringSubmatch(cells[0])
+		if m == nil {
+			continue
+		}
+		row, _ := strconv.Atoi(m[1])
+		t.links[row] = map[int]Link{}
+		for col := 1; col < len(cells) && col < len(header); col++ {
+			if p := gpuName.FindStringSubmatch(header[col]); p != nil {
+				peer, _ := strconv.Atoi(p[1])
+				if peer != row && cells[col] != "X" {
+					t.links[row][peer] = parseLink(cells[col])
+				}
+				continue
+			}
+			if header[col] == "NUMA Affinity" {
+				if node, err := strconv.Atoi(cells[col]); err == nil {
+					t.numa[row] = node
+				}
+			}
+		}
+	}
+	if header == nil {
+		return t, fmt.Errorf("nvidia-smi topo: no GPU matrix in output")
+	}
+	return t, nil
+}
+
+// parseLink reads one matrix cell.
+func parseLink(cell string) Link {
+	if m := nvCell.FindStringSubmatch(cell); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return Link{Type: cell, NVLinks: n}
+	}
+	if cell == "SOC" { // drivers before r384
+		return Link{Type: LinkSYS}
+	}
+	return Link{Type: cell}
+}
@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopo(t *testing.T) {
+	tests := []struct {
+		capture string
+		gpus    int
+		// islands lists each GPU's island once links are converted to devices.
+		islands []string
+		// pairs spot-checks links as {from, to} -> type.
+		pairs map[[2]int]string
+		numa  []int
+	}{
+		{
+			capture: "dgx-a100-topo.txt",
+			gpus:    8,
+			islands: []string{"nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0"},
+			pairs:   map[[2]int]string{{0, 1}: "NV12", {0, 7}: "NV12", {7, 0}: "NV12"},
+			numa:    []int{3, 3, 1, 1, 7, 7, 5, 5},
+		},
+		{
+			capture: "hgx-h100-topo.txt",
+			gpus:    8,
+			islands: []string{"nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0", "nvlink-0"},
+			pairs:   map[[2]int]string{{0, 4}: "NV18", {3, 2}: "NV18"},
+			numa:    []int{0, 0, 0, 0, 1, 1, 1, 1},
+		},
+		{
+			capture: "a6000-bridged-topo.txt",
+			gpus:    4,
+			islands: []string{"nvlink-0", "nvlink-0", "nvlink-2", "nvlink-2"},
+			pairs:   map[[2]int]string{{0, 1}: "NV4", {1, 2}: LinkSYS, {3, 2}: "NV4"},
+			numa:    []int{0, 0, 1, 1},
+		},
+		{
+			capture: "l40s-pcie-topo.txt",
+			gpus:    4,
+			islands: []string{"gpu-0", "gpu-1", "gpu-2", "gpu-3"},
+			pairs:   map[[2]int]string{{0, 1}: LinkPXB, {0, 2}: LinkSYS, {2, 3}: LinkPXB},
+			numa:    []int{0, 0, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.capture, func(t *testing.T) {
+			topo, err := parseTopo([]byte(readCapture(t, tt.capture)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(topo.links) != tt.gpus {
+				t.Fatalf("got %d GPU rows, want %d", len(topo.links), tt.gpus)
+			}
+			for pair, want := range tt.pairs {
+				if got := topo.links[pair[0]][pair[1]].Type; got != want {
+					t.Errorf("GPU %d to %d = %s, want %s", pair[0], pair[1], got, want)
+				}
+			}
+
+			var gpus []GPU
+			var numa []int
+			for i := 0; i < tt.gpus; i++ {
+				if len(topo.links[i]) != tt.gpus-1 {
+					t.Errorf("GPU %d has %d peers, want %d", i, len(topo.links[i]), tt.gpus-1)
+				}
+				gpus = append(gpus, GPU{Index: i, Links: topo.links[i]})
+				numa = append(numa, topo.numa[i])
+			}
+			var islands []string
+			for _, d := range Devices(gpus) {
+				islands = append(islands, d.Island)
+			}
+			if !reflect.DeepEqual(islands, tt.islands) {
+				t.Errorf("islands = %v, want %v", islands, tt.islands)
+			}
+			if !reflect.DeepEqual(numa, tt.numa) {
+				t.Errorf("NUMA affinity = %v, want %v", numa, tt.numa)
+			}
+		})
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	tests := map[string]Link{
+		"NV18": {Type: "NV18", NVLinks: 18},
+		"NV1":  {Type: "NV1", NVLinks: 1},
+		"PIX":  {Type: LinkPIX},
+		"NODE": {Type: LinkNODE},
+		"SOC":  {Type: LinkSYS},
+	}
+	for cell, want := range tests {
+		if got := parseLink(cell); got != want {
+			t.Errorf("parseLink(%q) = %+v, want %+v", cell, got, want)
+		}
+	}
+}
+
+func TestParseNVLinkRates(t *testing.T) {
+	rates := parseNVLinkRates([]byte(readCapture(t, "hgx-h100-nvlink.txt")))
+	if len(rates) != 8 {
+		t.Fatalf("got rates for %d GPUs, want 8", len(rates))
+	}
+	r := rates[5]
+	if r.links != 18 || int(r.total) != 478 || int(r.perLink()*18) != 478 {
+		t.Errorf("GPU 5 rate = %+v, want 18 links totalling 478 GB/s", r)
+	}
+}
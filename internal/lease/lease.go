@@ -1,12 +1,24 @@
+// Package lease reads the per-GPU coordination leases that releases before
+// GpuNodeAllocation used as the allocation ledger. The alloc GC migrates them.
 package lease
 
 import (
-	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	coordv1 "k8s.io/api/coordination/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	coordclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	labelManaged = "gpu.scheduling/managed"
+	labelPod     = "gpu.scheduling/pod"
+	labelPodNS   = "gpu.scheduling/pod-namespace"
+	labelNode    = "gpu.scheduling/node"
+	labelGPU     = "gpu.scheduling/gpu"
 )
 
 // LeaseName deterministically maps a node and GPU id to the lease resource identifier.
@@ -14,36 +26,46 @@ func LeaseName(node string, id int) string {
 	return fmt.Sprintf("gpu-%s-%d", node, id)
 }
 
-// TryAcquire attempts to create a lease per GPU id. Success indicates this pod owns the GPU.
-func TryAcquire(
-	ctx context.Context,
-	cli coordclient.CoordinationV1Interface,
-	ns, node, holder, podName string,
-	id int,
-) (bool, error) {
-	name := LeaseName(node, id)
-	lease := &coordv1.Lease{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: ns,
-			Labels: map[string]string{
-				"gpu.scheduling/managed": "true",
-				"gpu.scheduling/pod":     podName,
-			},
-		},
-		Spec: coordv1.LeaseSpec{
-			HolderIdentity: strPtr(holder),
-		},
-	}
-	if _, err := cli.Leases(ns).Create(ctx, lease, metav1.CreateOptions{}); err != nil {
-		return false, err
+// ManagedSelector matches every GPU lease written by the scheduler.
+func ManagedSelector() labels.Selector {
+	return labels.SelectorFromSet(labels.Set{labelManaged: "true"})
+}
+
+// PodOf returns the pod recorded on a managed lease. Leases without a pod
+// namespace label live in the pod's own namespace.
+func PodOf(l metav1.Object) types.NamespacedName {
+	ns := l.GetLabels()[labelPodNS]
+	if ns == "" {
+		ns = l.GetNamespace()
 	}
-	return true, nil
+	return types.NamespacedName{Namespace: ns, Name: l.GetLabels()[labelPod]}
 }
 
-// Release drops the lease so other pods may use the GPU.
-func Release(ctx context.Context, cli coordclient.CoordinationV1Interface, ns, node string, id int) error {
-	return cli.Leases(ns).Delete(ctx, LeaseName(node, id), metav1.DeleteOptions{})
+// Allocation reports the node and GPU id recorded on a managed lease, falling
+// back to the lease name for leases written without node and gpu labels.
+func Allocation(l *coordv1.Lease) (node string, id int, ok bool) {
+	node = l.Labels[labelNode]
+	id, err := strconv.Atoi(l.Labels[labelGPU])
+	if node == "" || err != nil {
+		return parseLeaseName(l.Name)
+	}
+	return node, id, true
 }
 
-func strPtr(s string) *string { return &s }
+// parseLeaseName splits a LeaseName back into node and GPU id. The id is the
+// numeric suffix, so node names containing dashes are recovered intact.
+func parseLeaseName(name string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(name, "gpu-")
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(rest, "-")
+	if i <= 0 {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(rest[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return rest[:i], id, true
+}
@@ -0,0 +1,243 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/alloc"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+)
+
+// gpuCache is the plugin's in-memory view of GPU state, fed by informers on
+// GpuNodeStatus, GpuNodeAllocation and legacy GPU leases. Reserve and
+// Unreserve update it optimistically, so the next scheduling cycle sees a
+// reservation before the informer delivers the ledger write.
+type gpuCache struct {
+	mu       sync.RWMutex
+	statuses map[string]*apiv1.GpuNodeStatus
+	ledger   map[string][]apiv1.PodAllocation
+	leases   map[types.NamespacedName]leasedGPU
+	// assumed holds reservations this plugin is writing or has written to
+	// the ledger that the informer has not delivered yet.
+	assumed map[types.UID]assumedPick
+	// gone holds deleted pods whose GPUs the ledger still lists until the GC
+	// prunes them; they no longer count as held.
+	gone map[types.UID]bool
+}
+
+// assumedPick is a reservation this plugin wrote to the ledger.
+type assumedPick struct {
+	node string
+	ids  []int
+}
+
+// leasedGPU is a GPU recorded by a legacy lease.
+type leasedGPU struct {
+	node   string
+	id     int
+	holder types.UID
+}
+
+func newGPUCache() *gpuCache {
+	return &gpuCache{
+		statuses: map[string]*apiv1.GpuNodeStatus{},
+		ledger:   map[string][]apiv1.PodAllocation{},
+		leases:   map[types.NamespacedName]leasedGPU{},
+		assumed:  map[types.UID]assumedPick{},
+		gone:     map[types.UID]bool{},
+	}
+}
+
+// register feeds the cache from the informers of the given cache. It does
+// not wait for them to sync.
+func (c *gpuCache) register(ctx context.Context, informers crcache.Informers) error {
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    c.update,
+		UpdateFunc: func(_, obj interface{}) { c.update(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tomb, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tomb.Obj
+			}
+			c.remove(obj)
+		},
+	}
+	for _, obj := range cachedObjects() {
+		inf, err := informers.GetInformer(ctx, obj, crcache.BlockUntilSynced(false))
+		if err != nil {
+			return fmt.Errorf("get informer for %T: %w", obj, err)
+		}
+		if _, err := inf.AddEventHandler(handler); err != nil {
+			return fmt.Errorf("add event handler for %T: %w", obj, err)
+		}
+	}
+	return nil
+}
+
+// waitForSync waits until the informers feeding the cache have synced, or
+// names the first one that has not when ctx ends. A missing CRD or RBAC rule
+// otherwise leaves the informer retrying its list forever.
+func (c *gpuCache) waitForSync(ctx context.Context, informers crcache.Informers) error {
+	for _, obj := range cachedObjects() {
+		inf, err := informers.GetInformer(ctx, obj, crcache.BlockUntilSynced(false))
+		if err != nil {
+			return fmt.Errorf("get informer for %T: %w", obj, err)
+		}
+		if !toolscache.WaitForCacheSync(ctx.Done(), inf.HasSynced) {
+			return fmt.Errorf("%s informer did not sync: %w", reflect.TypeOf(obj).Elem().Name(), context.Cause(ctx))
+		}
+	}
+	return nil
+}
+
+// cachedObjects are the kinds whose informers feed the cache.
+func cachedObjects() []crclient.Object {
+	return []crclient.Object{&apiv1.GpuNodeStatus{}, &apiv1.GpuNodeAllocation{}, &coordv1.Lease{}}
+}
+
+// watchPods frees the GPUs of deleted pods without waiting for the GC.
+func (c *gpuCache) watchPods(pods toolscache.SharedIndexInformer) error {
+	_, err := pods.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			if tomb, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tomb.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				c.podDeleted(pod)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("add pod event handler: %w", err)
+	}
+	return nil
+}
+
+func (c *gpuCache) podDeleted(pod *corev1.Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.assumed, pod.UID)
+	for _, a := range c.ledger[pod.Spec.NodeName] {
+		if types.UID(a.UID) == pod.UID {
+			c.gone[pod.UID] = true
+		}
+	}
+}
+
+func (c *gpuCache) update(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch o := obj.(type) {
+	case *apiv1.GpuNodeStatus:
+		c.statuses[o.Name] = o
+	case *apiv1.GpuNodeAllocation:
+		c.pruneGone(c.ledger[o.Name], o.Spec.Allocations)
+		c.ledger[o.Name] = o.Spec.Allocations
+		for _, a := range o.Spec.Allocations {
+			if pick, ok := c.assumed[types.UID(a.UID)]; ok && pick.node == o.Name {
+				delete(c.assumed, types.UID(a.UID))
+			}
+		}
+	case *coordv1.Lease:
+		node, id, ok := lease.Allocation(o)
+		if !ok || !lease.ManagedSelector().Matches(labels.Set(o.Labels)) {
+			return
+		}
+		gpu := leasedGPU{node: node, id: id}
+		if o.Spec.HolderIdentity != nil {
+			gpu.holder = types.UID(*o.Spec.HolderIdentity)
+		}
+		c.leases[types.NamespacedName{Namespace: o.Namespace, Name: o.Name}] = gpu
+	}
+}
+
+func (c *gpuCache) remove(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch o := obj.(type) {
+	case *apiv1.GpuNodeStatus:
+		delete(c.statuses, o.Name)
+	case *apiv1.GpuNodeAllocation:
+		c.pruneGone(c.ledger[o.Name], nil)
+		delete(c.ledger, o.Name)
+	case *coordv1.Lease:
+		delete(c.leases, types.NamespacedName{Namespace: o.Namespace, Name: o.Name})
+	}
+}
+
+// pruneGone forgets deleted pods whose ledger entries were removed.
+func (c *gpuCache) pruneGone(before, after []apiv1.PodAllocation) {
+	for _, a := range before {
+		uid := types.UID(a.UID)
+		if c.gone[uid] && !slices.ContainsFunc(after, func(b apiv1.PodAllocation) bool { return b.UID == a.UID }) {
+			delete(c.gone, uid)
+		}
+	}
+}
+
+// nodeStatus returns the cached GpuNodeStatus of a node. The object is shared
+// with the informer and must not be modified.
+func (c *gpuCache) nodeStatus(node string) (*apiv1.GpuNodeStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	gns, ok := c.statuses[node]
+	return gns, ok
+}
+
+// snapshot returns every GPU currently allocated, assumed or leased.
+func (c *gpuCache) snapshot() alloc.Set {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := alloc.Set{}
+	for node, allocs := range c.ledger {
+		for _, a := range allocs {
+			if c.gone[types.UID(a.UID)] {
+				continue
+			}
+			for _, id := range a.GPUIds {
+				out.Add(node, id, types.UID(a.UID))
+			}
+		}
+	}
+	for _, gpu := range c.leases {
+		out.Add(gpu.node, gpu.id, gpu.holder)
+	}
+	for uid, pick := range c.assumed {
+		for _, id := range pick.ids {
+			out.Add(pick.node, id, uid)
+		}
+	}
+	return out
+}
+
+// assume records a reservation about to be written to the ledger. It must
+// precede the write, whose informer event clears it.
+func (c *gpuCache) assume(uid types.UID, node string, ids []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assumed[uid] = assumedPick{node: node, ids: ids}
+}
+
+// forget drops the pod's reservation on node, whether assumed or already
+// delivered by the informer.
+func (c *gpuCache) forget(uid types.UID, node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.assumed, uid)
+	if allocs, ok := c.ledger[node]; ok {
+		c.ledger[node] = slices.DeleteFunc(slices.Clone(allocs), func(a apiv1.PodAllocation) bool {
+			return a.UID == string(uid)
+		})
+	}
+}
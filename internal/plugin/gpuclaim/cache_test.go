@@ -0,0 +1,198 @@
+package gpuclaim
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+func TestGPUCacheTracksReservations(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}},
+	}
+	p := newTestPlugin(t,
+		claim,
+		nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}),
+		gpuLease("other", "node-b", 0),
+	)
+
+	schedule := func(name string) *framework.CycleState {
+		t.Helper()
+		pod := claimPod("one")
+		pod.Name, pod.UID = name, types.UID("uid-"+name)
+		state := framework.NewCycleState()
+		if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+			t.Fatalf("PreFilter(%s): %v", name, st)
+		}
+		if st := p.Filter(ctx, state, pod, nodeInfo("node-a")); !st.IsSuccess() {
+			t.Fatalf("Filter(%s): %v", name, st)
+		}
+		if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+			t.Fatalf("Reserve(%s): %v", name, st)
+		}
+		return state
+	}
+
+	first := schedule("first")
+	if !p.gpus.snapshot().Has("node-a", 0) {
+		t.Fatalf("GPU 0 not assumed after Reserve")
+	}
+	// The second pod's Filter sees the assumed GPU without waiting for the informer.
+	schedule("second")
+	if data, _ := readState(first); data.chosenIDs[0] != 0 {
+		t.Errorf("first pod got %v, want [0]", data.chosenIDs)
+	}
+	if len(p.gpus.assumed) != 2 {
+		t.Errorf("assumed = %v, want both reservations", p.gpus.assumed)
+	}
+
+	// The informer delivers the ledger: assumptions are confirmed and dropped.
+	na := &apiv1.GpuNodeAllocation{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Name: "node-a"}, na); err != nil {
+		t.Fatal(err)
+	}
+	p.gpus.update(na)
+	if len(p.gpus.assumed) != 0 {
+		t.Errorf("assumed after informer update = %v, want none", p.gpus.assumed)
+	}
+
+	pod := claimPod("one")
+	pod.Name, pod.UID = "first", "uid-first"
+	p.Unreserve(ctx, first, pod, "node-a")
+	held := p.gpus.snapshot()
+	if held.Has("node-a", 0) || !held.Has("node-a", 1) {
+		t.Errorf("held after Unreserve = %v, want only node-a/1", held)
+	}
+
+	if !held.Has("node-b", 0) {
+		t.Errorf("legacy lease not tracked: %v", held)
+	}
+	p.gpus.remove(gpuLease("other", "node-b", 0))
+	if p.gpus.snapshot().Has("node-b", 0) {
+		t.Errorf("legacy lease still tracked after delete")
+	}
+
+	p.gpus.remove(&apiv1.GpuNodeStatus{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}})
+	if st := p.Filter(ctx, first, &corev1.Pod{}, nodeInfo("node-a")); st.Code() != framework.UnschedulableAndUnresolvable {
+		t.Errorf("Filter after GpuNodeStatus delete = %v, want UnschedulableAndUnresolvable", st)
+	}
+}
+
+func TestGPUCacheDropsDeletedPods(t *testing.T) {
+	c := newGPUCache()
+	na := &apiv1.GpuNodeAllocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Spec: apiv1.GpuNodeAllocationSpec{NodeName: "node-a", Allocations: []apiv1.PodAllocation{
+			{Namespace: "default", Name: "done", UID: "uid-done", GPUIds: []int{0}},
+		}},
+	}
+	c.update(na)
+
+	done := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "done", UID: "uid-done"}, Spec: corev1.PodSpec{NodeName: "node-a"}}
+	c.podDeleted(done)
+	if c.snapshot().Has("node-a", 0) {
+		t.Errorf("GPU of deleted pod still held")
+	}
+
+	// The GC prunes the entry; the cache forgets the pod.
+	pruned := na.DeepCopy()
+	pruned.Spec.Allocations = nil
+	c.update(pruned)
+	if len(c.gone) != 0 {
+		t.Errorf("gone = %v, want empty after prune", c.gone)
+	}
+}
+
+func TestGPUCacheLedgerEventBeforeAcquireReturns(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}},
+	}
+	status := nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1})
+	p := newTestPlugin(t, claim, status)
+
+	// The informer delivers the ledger write before Acquire returns.
+	deliver := func(obj crclient.Object) {
+		if na, ok := obj.(*apiv1.GpuNodeAllocation); ok {
+			p.gpus.update(na.DeepCopy())
+		}
+	}
+	p.crcClient = crfake.NewClientBuilder().
+		WithScheme(p.crcClient.Scheme()).
+		WithObjects(claim, status).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c crclient.WithWatch, obj crclient.Object, opts ...crclient.CreateOption) error {
+				err := c.Create(ctx, obj, opts...)
+				if err == nil {
+					deliver(obj)
+				}
+				return err
+			},
+			Update: func(ctx context.Context, c crclient.WithWatch, obj crclient.Object, opts ...crclient.UpdateOption) error {
+				err := c.Update(ctx, obj, opts...)
+				if err == nil {
+					deliver(obj)
+				}
+				return err
+			},
+		}).
+		Build()
+
+	pod := claimPod("one")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("Reserve: %v", st)
+	}
+	if len(p.gpus.assumed) != 0 {
+		t.Errorf("assumed = %v, want none once the ledger write was delivered", p.gpus.assumed)
+	}
+
+	// The pod finishes without being deleted and the GC prunes its entry.
+	na := &apiv1.GpuNodeAllocation{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Name: "node-a"}, na); err != nil {
+		t.Fatal(err)
+	}
+	na.Spec.Allocations = nil
+	p.gpus.update(na)
+	if held := p.gpus.snapshot(); len(held) != 0 {
+		t.Errorf("held after GC = %v, want none", held)
+	}
+}
+
+// stuckInformers hands out informers that never sync, as when a CRD is missing.
+type stuckInformers struct{ crcache.Informers }
+
+type stuckInformer struct{ crcache.Informer }
+
+func (stuckInformers) GetInformer(context.Context, crclient.Object, ...crcache.InformerGetOption) (crcache.Informer, error) {
+	return stuckInformer{}, nil
+}
+
+func (stuckInformer) HasSynced() bool { return false }
+
+func TestGPUCacheWaitForSyncNamesInformer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := newGPUCache().waitForSync(ctx, stuckInformers{})
+	if err == nil || !strings.Contains(err.Error(), "GpuNodeStatus informer did not sync") {
+		t.Errorf("waitForSync = %v, want GpuNodeStatus named", err)
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	schedconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+)
+
+// SchemeGroupVersion is the internal version of the scheduler config group.
+var SchemeGroupVersion = schedconfig.SchemeGroupVersion
+
+var (
+	// SchemeBuilder registers the internal plugin args.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme registers the internal plugin args with a Scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &GpuClaimPluginArgs{})
+	return nil
+}
@@ -0,0 +1,28 @@
+// Package scheme registers the GpuClaimPlugin args with kube-scheduler, so
+// its pluginConfig section decodes them into typed, defaulted args.
+package scheme
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	schedv1 "k8s.io/kube-scheduler/config/v1"
+	schedconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	schedscheme "k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+	configv1 "github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config/v1"
+)
+
+func init() {
+	// The scheduler builds its plugin args conversion scheme lazily from the
+	// in-tree builders; the decoding scheme already exists, so add to it too.
+	schedconfig.SchemeBuilder.Register(config.AddToScheme)
+	schedv1.SchemeBuilder.Register(configv1.AddToScheme)
+	AddToScheme(schedscheme.Scheme)
+}
+
+// AddToScheme registers the internal and v1 plugin args with a Scheme.
+func AddToScheme(scheme *runtime.Scheme) {
+	utilruntime.Must(config.AddToScheme(scheme))
+	utilruntime.Must(configv1.AddToScheme(scheme))
+}
@@ -0,0 +1,110 @@
+package scheme
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	schedconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	schedscheme "k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+)
+
+func TestDecodePluginConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    config.GpuClaimPluginArgs
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			args: "{}",
+			want: config.GpuClaimPluginArgs{
+				DefaultGPUCount: 1,
+				ScoringStrategy: config.ScoringStrategy{Type: config.Topology, TopologyWeight: 1, AllocationWeight: 1, FragmentationWeight: 1},
+				GCInterval:      durationOf(30 * time.Second),
+				MaxGPUID:        16,
+				UnknownHealth:   config.AllowUnknown,
+			},
+		},
+		{
+			name: "explicit",
+			args: `{defaultGPUCount: 2, scoringStrategy: {type: Topology}, gcInterval: 1m, maxGPUID: 63, unknownHealth: Exclude}`,
+			want: config.GpuClaimPluginArgs{
+				DefaultGPUCount: 2,
+				ScoringStrategy: config.ScoringStrategy{Type: config.Topology, TopologyWeight: 1, AllocationWeight: 1, FragmentationWeight: 1},
+				GCInterval:      durationOf(time.Minute),
+				MaxGPUID:        63,
+				UnknownHealth:   config.ExcludeUnknown,
+			},
+		},
+		{
+			name: "ratio strategy with default shape",
+			args: `{scoringStrategy: {type: RequestedToCapacityRatio, topologyWeight: 2}}`,
+			want: config.GpuClaimPluginArgs{
+				DefaultGPUCount: 1,
+				ScoringStrategy: config.ScoringStrategy{
+					Type:                config.RequestedToCapacityRatio,
+					TopologyWeight:      2,
+					AllocationWeight:    1,
+					FragmentationWeight: 1,
+					RequestedToCapacityRatio: &config.RequestedToCapacityRatioParam{
+						Shape: []config.UtilizationShapePoint{{Utilization: 0, Score: 0}, {Utilization: 100, Score: 10}},
+					},
+				},
+				GCInterval:    durationOf(30 * time.Second),
+				MaxGPUID:      16,
+				UnknownHealth: config.AllowUnknown,
+			},
+		},
+		{
+			name:    "unknown field",
+			args:    `{gpuCount: 2}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(`apiVersion: kubescheduler.config.k8s.io/v1
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: gpu-scheduler
+  pluginConfig:
+  - name: GpuClaimPlugin
+    args: ` + tt.args + "\n")
+			obj, _, err := schedscheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected a decoding error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := obj.(*schedconfig.KubeSchedulerConfiguration)
+			var got *config.GpuClaimPluginArgs
+			for _, pc := range cfg.Profiles[0].PluginConfig {
+				if pc.Name == "GpuClaimPlugin" {
+					got = pc.Args.(*config.GpuClaimPluginArgs)
+				}
+			}
+			if got == nil {
+				t.Fatal("GpuClaimPlugin args not decoded")
+			}
+			got.TypeMeta = tt.want.TypeMeta
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("args = %+v, want %+v", *got, tt.want)
+			}
+			if err := config.ValidateGpuClaimPluginArgs(field.NewPath("args"), got); err != nil {
+				t.Errorf("defaulted args invalid: %v", err)
+			}
+		})
+	}
+}
+
+func durationOf(d time.Duration) metav1.Duration { return metav1.Duration{Duration: d} }
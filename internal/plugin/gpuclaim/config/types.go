@@ -0,0 +1,84 @@
+// Package config holds the internal GpuClaimPlugin arguments the scheduler
+// hands to the plugin after decoding, defaulting and converting its
+// KubeSchedulerConfiguration pluginConfig.
+package config
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScoringStrategyType selects how Score ranks nodes.
+type ScoringStrategyType string
+
+const (
+	// Topology ranks nodes by the interconnect quality of the device set
+	// the claim's policy picks on them.
+	Topology ScoringStrategyType = "Topology"
+	// MostAllocated favors nodes with the highest share of GPUs in use,
+	// packing pods to keep whole nodes free.
+	MostAllocated ScoringStrategyType = "MostAllocated"
+	// LeastAllocated favors nodes with the lowest share of GPUs in use,
+	// spreading pods across nodes.
+	LeastAllocated ScoringStrategyType = "LeastAllocated"
+	// RequestedToCapacityRatio scores the share of GPUs in use through a
+	// configurable piecewise linear shape.
+	RequestedToCapacityRatio ScoringStrategyType = "RequestedToCapacityRatio"
+)
+
+// ScoringStrategy configures node scoring. Every strategy other than
+// Topology scores GPU usage and combines it with the topology score. Every
+// strategy also blends in how fragmented the pick leaves the node's free GPUs.
+type ScoringStrategy struct {
+	Type ScoringStrategyType
+	// TopologyWeight weighs the topology score against the usage score.
+	TopologyWeight int32
+	// AllocationWeight weighs the usage score against the topology score.
+	AllocationWeight int32
+	// FragmentationWeight weighs the fragmentation score against the others.
+	FragmentationWeight int32
+	// RequestedToCapacityRatio holds the shape for that strategy.
+	RequestedToCapacityRatio *RequestedToCapacityRatioParam
+}
+
+// RequestedToCapacityRatioParam defines the usage-to-score shape.
+type RequestedToCapacityRatioParam struct {
+	Shape []UtilizationShapePoint
+}
+
+// UtilizationShapePoint is one point of the shape.
+type UtilizationShapePoint struct {
+	// Utilization is the share of the node's GPUs in use, 0 to 100.
+	Utilization int32
+	// Score is the node score at that utilization, 0 to 10.
+	Score int32
+}
+
+// UnknownHealthPolicy decides whether devices of unknown health are allocated.
+type UnknownHealthPolicy string
+
+const (
+	// AllowUnknown allocates devices whose health the agent has not determined.
+	AllowUnknown UnknownHealthPolicy = "Allow"
+	// ExcludeUnknown allocates only devices reported Healthy.
+	ExcludeUnknown UnknownHealthPolicy = "Exclude"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GpuClaimPluginArgs configures GpuClaimPlugin.
+type GpuClaimPluginArgs struct {
+	metav1.TypeMeta
+
+	// DefaultGPUCount is used for claims that leave devices.count unset.
+	DefaultGPUCount int
+	// ScoringStrategy selects how nodes are ranked.
+	ScoringStrategy ScoringStrategy
+	// GCInterval is how often GPUs of finished or deleted pods are released.
+	GCInterval metav1.Duration
+	// MaxGPUID is the highest device ID the plugin allocates; devices with a
+	// higher ID are ignored.
+	MaxGPUID int
+	// UnknownHealth decides whether devices with Unknown or unreported health
+	// are allocated; devices with any other health but Healthy never are.
+	UnknownHealth UnknownHealthPolicy
+}
@@ -0,0 +1,72 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+)
+
+// RegisterConversions adds the conversions between the versioned and
+// internal args. They are written by hand; the args are small enough that
+// conversion-gen is not worth wiring into the build.
+func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddConversionFunc((*GpuClaimPluginArgs)(nil), (*config.GpuClaimPluginArgs)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1_GpuClaimPluginArgs_To_config_GpuClaimPluginArgs(a.(*GpuClaimPluginArgs), b.(*config.GpuClaimPluginArgs), scope)
+	}); err != nil {
+		return err
+	}
+	return s.AddConversionFunc((*config.GpuClaimPluginArgs)(nil), (*GpuClaimPluginArgs)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_GpuClaimPluginArgs_To_v1_GpuClaimPluginArgs(a.(*config.GpuClaimPluginArgs), b.(*GpuClaimPluginArgs), scope)
+	})
+}
+
+// Convert_v1_GpuClaimPluginArgs_To_config_GpuClaimPluginArgs converts
+// defaulted versioned args to the internal type. Unset fields stay zero.
+func Convert_v1_GpuClaimPluginArgs_To_config_GpuClaimPluginArgs(in *GpuClaimPluginArgs, out *config.GpuClaimPluginArgs, _ conversion.Scope) error {
+	out.DefaultGPUCount = int(ptr.Deref(in.DefaultGPUCount, 0))
+	if in.ScoringStrategy != nil {
+		out.ScoringStrategy = config.ScoringStrategy{
+			Type:                config.ScoringStrategyType(in.ScoringStrategy.Type),
+			TopologyWeight:      ptr.Deref(in.ScoringStrategy.TopologyWeight, 0),
+			AllocationWeight:    ptr.Deref(in.ScoringStrategy.AllocationWeight, 0),
+			FragmentationWeight: ptr.Deref(in.ScoringStrategy.FragmentationWeight, 0),
+		}
+		if r := in.ScoringStrategy.RequestedToCapacityRatio; r != nil {
+			out.ScoringStrategy.RequestedToCapacityRatio = &config.RequestedToCapacityRatioParam{}
+			for _, pt := range r.Shape {
+				out.ScoringStrategy.RequestedToCapacityRatio.Shape = append(out.ScoringStrategy.RequestedToCapacityRatio.Shape,
+					config.UtilizationShapePoint{Utilization: pt.Utilization, Score: pt.Score})
+			}
+		}
+	}
+	out.GCInterval = ptr.Deref(in.GCInterval, metav1.Duration{})
+	out.MaxGPUID = int(ptr.Deref(in.MaxGPUID, 0))
+	out.UnknownHealth = config.UnknownHealthPolicy(ptr.Deref(in.UnknownHealth, ""))
+	return nil
+}
+
+// Convert_config_GpuClaimPluginArgs_To_v1_GpuClaimPluginArgs converts
+// internal args back to the versioned type.
+func Convert_config_GpuClaimPluginArgs_To_v1_GpuClaimPluginArgs(in *config.GpuClaimPluginArgs, out *GpuClaimPluginArgs, _ conversion.Scope) error {
+	out.DefaultGPUCount = ptr.To(int32(in.DefaultGPUCount))
+	out.ScoringStrategy = &ScoringStrategy{
+		Type:                ScoringStrategyType(in.ScoringStrategy.Type),
+		TopologyWeight:      ptr.To(in.ScoringStrategy.TopologyWeight),
+		AllocationWeight:    ptr.To(in.ScoringStrategy.AllocationWeight),
+		FragmentationWeight: ptr.To(in.ScoringStrategy.FragmentationWeight),
+	}
+	if r := in.ScoringStrategy.RequestedToCapacityRatio; r != nil {
+		out.ScoringStrategy.RequestedToCapacityRatio = &RequestedToCapacityRatioParam{}
+		for _, pt := range r.Shape {
+			out.ScoringStrategy.RequestedToCapacityRatio.Shape = append(out.ScoringStrategy.RequestedToCapacityRatio.Shape,
+				UtilizationShapePoint{Utilization: pt.Utilization, Score: pt.Score})
+		}
+	}
+	out.GCInterval = ptr.To(in.GCInterval)
+	out.MaxGPUID = ptr.To(int32(in.MaxGPUID))
+	out.UnknownHealth = ptr.To(UnknownHealthPolicy(in.UnknownHealth))
+	return nil
+}
@@ -0,0 +1,59 @@
+package v1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	defaultGPUCount   = 1
+	defaultGCInterval = 30 * time.Second
+	// defaultMaxGPUID assumes at most 17 devices per host. Hosts with virtual
+	// GPUs (NVIDIA H200, B200) can expose up to 64.
+	defaultMaxGPUID = 16
+	defaultWeight   = 1
+)
+
+// defaultShape rises linearly with usage, like MostAllocated.
+var defaultShape = []UtilizationShapePoint{{Utilization: 0, Score: 0}, {Utilization: 100, Score: 10}}
+
+// SetDefaults_GpuClaimPluginArgs fills unset GpuClaimPlugin args.
+func SetDefaults_GpuClaimPluginArgs(args *GpuClaimPluginArgs) {
+	if args.DefaultGPUCount == nil {
+		args.DefaultGPUCount = ptr.To[int32](defaultGPUCount)
+	}
+	if args.ScoringStrategy == nil {
+		args.ScoringStrategy = &ScoringStrategy{}
+	}
+	if args.ScoringStrategy.Type == "" {
+		args.ScoringStrategy.Type = Topology
+	}
+	if args.ScoringStrategy.TopologyWeight == nil {
+		args.ScoringStrategy.TopologyWeight = ptr.To[int32](defaultWeight)
+	}
+	if args.ScoringStrategy.AllocationWeight == nil {
+		args.ScoringStrategy.AllocationWeight = ptr.To[int32](defaultWeight)
+	}
+	if args.ScoringStrategy.FragmentationWeight == nil {
+		args.ScoringStrategy.FragmentationWeight = ptr.To[int32](defaultWeight)
+	}
+	if args.ScoringStrategy.Type == RequestedToCapacityRatio {
+		if args.ScoringStrategy.RequestedToCapacityRatio == nil {
+			args.ScoringStrategy.RequestedToCapacityRatio = &RequestedToCapacityRatioParam{}
+		}
+		if len(args.ScoringStrategy.RequestedToCapacityRatio.Shape) == 0 {
+			args.ScoringStrategy.RequestedToCapacityRatio.Shape = append([]UtilizationShapePoint(nil), defaultShape...)
+		}
+	}
+	if args.GCInterval == nil {
+		args.GCInterval = &metav1.Duration{Duration: defaultGCInterval}
+	}
+	if args.MaxGPUID == nil {
+		args.MaxGPUID = ptr.To[int32](defaultMaxGPUID)
+	}
+	if args.UnknownHealth == nil {
+		args.UnknownHealth = ptr.To(AllowUnknown)
+	}
+}
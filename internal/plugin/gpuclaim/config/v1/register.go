@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	schedv1 "k8s.io/kube-scheduler/config/v1"
+)
+
+// SchemeGroupVersion is kubescheduler.config.k8s.io/v1.
+var SchemeGroupVersion = schedv1.SchemeGroupVersion
+
+var (
+	// SchemeBuilder registers the versioned plugin args with their defaulting
+	// and conversion functions.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes, addDefaultingFuncs, RegisterConversions)
+	// AddToScheme registers the versioned plugin args with a Scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &GpuClaimPluginArgs{})
+	return nil
+}
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&GpuClaimPluginArgs{}, func(obj interface{}) {
+		SetDefaults_GpuClaimPluginArgs(obj.(*GpuClaimPluginArgs))
+	})
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package v1 holds the versioned GpuClaimPlugin arguments accepted in the
+// kubescheduler.config.k8s.io/v1 pluginConfig section.
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScoringStrategyType selects how Score ranks nodes.
+type ScoringStrategyType string
+
+const (
+	// Topology ranks nodes by the interconnect quality of the device set
+	// the claim's policy picks on them.
+	Topology ScoringStrategyType = "Topology"
+	// MostAllocated favors nodes with the highest share of GPUs in use,
+	// packing pods to keep whole nodes free.
+	MostAllocated ScoringStrategyType = "MostAllocated"
+	// LeastAllocated favors nodes with the lowest share of GPUs in use,
+	// spreading pods across nodes.
+	LeastAllocated ScoringStrategyType = "LeastAllocated"
+	// RequestedToCapacityRatio scores the share of GPUs in use through a
+	// configurable piecewise linear shape.
+	RequestedToCapacityRatio ScoringStrategyType = "RequestedToCapacityRatio"
+)
+
+// ScoringStrategy configures node scoring. Every strategy other than
+// Topology scores GPU usage and combines it with the topology score. Every
+// strategy also blends in how fragmented the pick leaves the node's free GPUs.
+type ScoringStrategy struct {
+	// Type defaults to Topology.
+	Type ScoringStrategyType `json:"type,omitempty"`
+	// TopologyWeight weighs the topology score against the usage score.
+	// Defaults to 1.
+	TopologyWeight *int32 `json:"topologyWeight,omitempty"`
+	// AllocationWeight weighs the usage score against the topology score.
+	// Defaults to 1.
+	AllocationWeight *int32 `json:"allocationWeight,omitempty"`
+	// FragmentationWeight weighs the fragmentation score against the others;
+	// 0 ranks nodes without it. Defaults to 1.
+	FragmentationWeight *int32 `json:"fragmentationWeight,omitempty"`
+	// RequestedToCapacityRatio holds the shape for that strategy. The shape
+	// defaults to a linear rise from 0 at idle to 10 when full.
+	RequestedToCapacityRatio *RequestedToCapacityRatioParam `json:"requestedToCapacityRatio,omitempty"`
+}
+
+// RequestedToCapacityRatioParam defines the usage-to-score shape.
+type RequestedToCapacityRatioParam struct {
+	Shape []UtilizationShapePoint `json:"shape,omitempty"`
+}
+
+// UtilizationShapePoint is one point of the shape.
+type UtilizationShapePoint struct {
+	// Utilization is the share of the node's GPUs in use, 0 to 100.
+	Utilization int32 `json:"utilization"`
+	// Score is the node score at that utilization, 0 to 10.
+	Score int32 `json:"score"`
+}
+
+// UnknownHealthPolicy decides whether devices of unknown health are allocated.
+type UnknownHealthPolicy string
+
+const (
+	// AllowUnknown allocates devices whose health the agent has not determined.
+	AllowUnknown UnknownHealthPolicy = "Allow"
+	// ExcludeUnknown allocates only devices reported Healthy.
+	ExcludeUnknown UnknownHealthPolicy = "Exclude"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GpuClaimPluginArgs configures GpuClaimPlugin.
+type GpuClaimPluginArgs struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// DefaultGPUCount is used for claims that leave devices.count unset.
+	// Defaults to 1.
+	DefaultGPUCount *int32 `json:"defaultGPUCount,omitempty"`
+	// ScoringStrategy selects how nodes are ranked.
+	ScoringStrategy *ScoringStrategy `json:"scoringStrategy,omitempty"`
+	// GCInterval is how often GPUs of finished or deleted pods are released.
+	// Defaults to 30s.
+	GCInterval *metav1.Duration `json:"gcInterval,omitempty"`
+	// MaxGPUID is the highest device ID the plugin allocates; devices with a
+	// higher ID are ignored. Defaults to 16.
+	MaxGPUID *int32 `json:"maxGPUID,omitempty"`
+	// UnknownHealth decides whether devices with Unknown or unreported health
+	// are allocated; devices with any other health but Healthy never are.
+	// Defaults to Allow.
+	UnknownHealth *UnknownHealthPolicy `json:"unknownHealth,omitempty"`
+}
@@ -0,0 +1,132 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Code generated by deepcopy-gen. DO NOT EDIT.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GpuClaimPluginArgs) DeepCopyInto(out *GpuClaimPluginArgs) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	if in.DefaultGPUCount != nil {
+		in, out := &in.DefaultGPUCount, &out.DefaultGPUCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ScoringStrategy != nil {
+		in, out := &in.ScoringStrategy, &out.ScoringStrategy
+		*out = new(ScoringStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GCInterval != nil {
+		in, out := &in.GCInterval, &out.GCInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MaxGPUID != nil {
+		in, out := &in.MaxGPUID, &out.MaxGPUID
+		*out = new(int32)
+		**out = **in
+	}
+	if in.UnknownHealth != nil {
+		in, out := &in.UnknownHealth, &out.UnknownHealth
+		*out = new(UnknownHealthPolicy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuClaimPluginArgs.
+func (in *GpuClaimPluginArgs) DeepCopy() *GpuClaimPluginArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(GpuClaimPluginArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GpuClaimPluginArgs) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestedToCapacityRatioParam) DeepCopyInto(out *RequestedToCapacityRatioParam) {
+	*out = *in
+	if in.Shape != nil {
+		in, out := &in.Shape, &out.Shape
+		*out = make([]UtilizationShapePoint, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestedToCapacityRatioParam.
+func (in *RequestedToCapacityRatioParam) DeepCopy() *RequestedToCapacityRatioParam {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestedToCapacityRatioParam)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScoringStrategy) DeepCopyInto(out *ScoringStrategy) {
+	*out = *in
+	if in.TopologyWeight != nil {
+		in, out := &in.TopologyWeight, &out.TopologyWeight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllocationWeight != nil {
+		in, out := &in.AllocationWeight, &out.AllocationWeight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FragmentationWeight != nil {
+		in, out := &in.FragmentationWeight, &out.FragmentationWeight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RequestedToCapacityRatio != nil {
+		in, out := &in.RequestedToCapacityRatio, &out.RequestedToCapacityRatio
+		*out = new(RequestedToCapacityRatioParam)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScoringStrategy.
+func (in *ScoringStrategy) DeepCopy() *ScoringStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ScoringStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UtilizationShapePoint) DeepCopyInto(out *UtilizationShapePoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UtilizationShapePoint.
+func (in *UtilizationShapePoint) DeepCopy() *UtilizationShapePoint {
+	if in == nil {
+		return nil
+	}
+	out := new(UtilizationShapePoint)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// maxGPUIDLimit bounds MaxGPUID to a sane number of devices per host.
+	maxGPUIDLimit = 255
+	// maxWeight bounds the scoring weights, as for NodeResourcesFit.
+	maxWeight = 100
+	// maxUtilization and maxShapeScore bound the shape points, as for
+	// RequestedToCapacityRatio in NodeResourcesFit.
+	maxUtilization = 100
+	maxShapeScore  = 10
+)
+
+var supportedStrategies = []ScoringStrategyType{Topology, MostAllocated, LeastAllocated, RequestedToCapacityRatio}
+
+// ValidateGpuClaimPluginArgs validates defaulted GpuClaimPlugin args.
+func ValidateGpuClaimPluginArgs(path *field.Path, args *GpuClaimPluginArgs) error {
+	var errs field.ErrorList
+	if args.MaxGPUID < 0 || args.MaxGPUID > maxGPUIDLimit {
+		errs = append(errs, field.Invalid(path.Child("maxGPUID"), args.MaxGPUID, "must be between 0 and 255"))
+	}
+	if args.DefaultGPUCount < 1 || args.DefaultGPUCount > args.MaxGPUID+1 {
+		errs = append(errs, field.Invalid(path.Child("defaultGPUCount"), args.DefaultGPUCount, "must be between 1 and maxGPUID+1"))
+	}
+	if args.GCInterval.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("gcInterval"), args.GCInterval.Duration.String(), "must be positive"))
+	}
+	errs = append(errs, validateScoringStrategy(path.Child("scoringStrategy"), &args.ScoringStrategy)...)
+	switch args.UnknownHealth {
+	case AllowUnknown, ExcludeUnknown:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("unknownHealth"), args.UnknownHealth, []UnknownHealthPolicy{AllowUnknown, ExcludeUnknown}))
+	}
+	return errs.ToAggregate()
+}
+
+func validateScoringStrategy(path *field.Path, s *ScoringStrategy) field.ErrorList {
+	var errs field.ErrorList
+	switch s.Type {
+	case Topology:
+	case MostAllocated, LeastAllocated:
+	case RequestedToCapacityRatio:
+		if s.RequestedToCapacityRatio == nil {
+			errs = append(errs, field.Required(path.Child("requestedToCapacityRatio"), "required for RequestedToCapacityRatio"))
+		} else {
+			errs = append(errs, validateShape(path.Child("requestedToCapacityRatio", "shape"), s.RequestedToCapacityRatio.Shape)...)
+		}
+	default:
+		return append(errs, field.NotSupported(path.Child("type"), s.Type, supportedStrategies))
+	}
+	if s.TopologyWeight < 0 || s.TopologyWeight > maxWeight {
+		errs = append(errs, field.Invalid(path.Child("topologyWeight"), s.TopologyWeight, "must be between 0 and 100"))
+	}
+	if s.Type != Topology && (s.AllocationWeight < 1 || s.AllocationWeight > maxWeight) {
+		errs = append(errs, field.Invalid(path.Child("allocationWeight"), s.AllocationWeight, "must be between 1 and 100"))
+	}
+	if s.FragmentationWeight < 0 || s.FragmentationWeight > maxWeight {
+		errs = append(errs, field.Invalid(path.Child("fragmentationWeight"), s.FragmentationWeight, "must be between 0 and 100"))
+	}
+	return errs
+}
+
+// validateShape requires at least one point, utilizations rising strictly
+// within 0..100 and scores within 0..10.
+func validateShape(path *field.Path, shape []UtilizationShapePoint) field.ErrorList {
+	var errs field.ErrorList
+	if len(shape) == 0 {
+		return append(errs, field.Required(path, "at least one point is required"))
+	}
+	for i, pt := range shape {
+		if pt.Utilization < 0 || pt.Utilization > maxUtilization {
+			errs = append(errs, field.Invalid(path.Index(i).Child("utilization"), pt.Utilization, "must be between 0 and 100"))
+		} else if i > 0 && pt.Utilization <= shape[i-1].Utilization {
+			errs = append(errs, field.Invalid(path.Index(i).Child("utilization"), pt.Utilization, "must be greater than the previous point's"))
+		}
+		if pt.Score < 0 || pt.Score > maxShapeScore {
+			errs = append(errs, field.Invalid(path.Index(i).Child("score"), pt.Score, "must be between 0 and 10"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateGpuClaimPluginArgs(t *testing.T) {
+	valid := func() GpuClaimPluginArgs {
+		return GpuClaimPluginArgs{
+			DefaultGPUCount: 1,
+			ScoringStrategy: ScoringStrategy{Type: Topology, TopologyWeight: 1, AllocationWeight: 1, FragmentationWeight: 1},
+			GCInterval:      metav1.Duration{Duration: 30 * time.Second},
+			MaxGPUID:        16,
+			UnknownHealth:   AllowUnknown,
+		}
+	}
+	tests := []struct {
+		name    string
+		mutate  func(*GpuClaimPluginArgs)
+		wantErr bool
+	}{
+		{name: "valid", mutate: func(*GpuClaimPluginArgs) {}},
+		{name: "zero default count", mutate: func(a *GpuClaimPluginArgs) { a.DefaultGPUCount = 0 }, wantErr: true},
+		{name: "default count above devices", mutate: func(a *GpuClaimPluginArgs) { a.DefaultGPUCount = 18 }, wantErr: true},
+		{name: "negative max id", mutate: func(a *GpuClaimPluginArgs) { a.MaxGPUID = -1 }, wantErr: true},
+		{name: "zero gc interval", mutate: func(a *GpuClaimPluginArgs) { a.GCInterval.Duration = 0 }, wantErr: true},
+		{name: "unknown strategy", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.Type = "Random" }, wantErr: true},
+		{name: "exclude unknown health", mutate: func(a *GpuClaimPluginArgs) { a.UnknownHealth = ExcludeUnknown }},
+		{name: "unknown health policy", mutate: func(a *GpuClaimPluginArgs) { a.UnknownHealth = "Maybe" }, wantErr: true},
+		{name: "most allocated", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.Type = MostAllocated }},
+		{name: "zero allocation weight", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = LeastAllocated
+			a.ScoringStrategy.AllocationWeight = 0
+		}, wantErr: true},
+		{name: "topology weight above limit", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = MostAllocated
+			a.ScoringStrategy.TopologyWeight = 101
+		}, wantErr: true},
+		{name: "topology weight above limit for Topology", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.TopologyWeight = 101 }, wantErr: true},
+		{name: "no fragmentation weight", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.FragmentationWeight = 0 }},
+		{name: "negative fragmentation weight", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.FragmentationWeight = -1 }, wantErr: true},
+		{name: "fragmentation weight above limit", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = MostAllocated
+			a.ScoringStrategy.FragmentationWeight = 101
+		}, wantErr: true},
+		{name: "ratio shape", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = RequestedToCapacityRatio
+			a.ScoringStrategy.RequestedToCapacityRatio = &RequestedToCapacityRatioParam{
+				Shape: []UtilizationShapePoint{{Utilization: 0, Score: 10}, {Utilization: 100, Score: 0}},
+			}
+		}},
+		{name: "ratio without shape", mutate: func(a *GpuClaimPluginArgs) { a.ScoringStrategy.Type = RequestedToCapacityRatio }, wantErr: true},
+		{name: "ratio shape not increasing", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = RequestedToCapacityRatio
+			a.ScoringStrategy.RequestedToCapacityRatio = &RequestedToCapacityRatioParam{
+				Shape: []UtilizationShapePoint{{Utilization: 50, Score: 5}, {Utilization: 50, Score: 10}},
+			}
+		}, wantErr: true},
+		{name: "ratio score above limit", mutate: func(a *GpuClaimPluginArgs) {
+			a.ScoringStrategy.Type = RequestedToCapacityRatio
+			a.ScoringStrategy.RequestedToCapacityRatio = &RequestedToCapacityRatioParam{
+				Shape: []UtilizationShapePoint{{Utilization: 100, Score: 11}},
+			}
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := valid()
+			tt.mutate(&args)
+			err := ValidateGpuClaimPluginArgs(field.NewPath("args"), &args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+var _ framework.EnqueueExtensions = &Plugin{}
+
+// Custom resources are registered as <plural>.<version>.<group>; the
+// scheduler watches them through dynamic informers.
+var (
+	gpuNodeStatusResource     = crdResource("gpunodestatuses")
+	gpuNodeAllocationResource = crdResource("gpunodeallocations")
+	gpuClaimResource          = crdResource("gpuclaims")
+	leaseResource             = framework.EventResource("leases.v1.coordination.k8s.io")
+)
+
+func crdResource(plural string) framework.EventResource {
+	return framework.EventResource(fmt.Sprintf("%s.%s.%s", plural, apiv1.GroupVersion.Version, apiv1.GroupVersion.Group))
+}
+
+// EventsToRegister requeues pods rejected by this plugin only when GPUs may
+// have become available to them or their gang's pod group was admitted.
+func (p *Plugin) EventsToRegister(ctx context.Context) ([]framework.ClusterEventWithHint, error) {
+	events := []framework.ClusterEventWithHint{
+		{Event: framework.ClusterEvent{Resource: gpuNodeStatusResource, ActionType: framework.Add | framework.Update}, QueueingHintFn: p.isGPUNodeStatusGrown},
+		{Event: framework.ClusterEvent{Resource: gpuNodeAllocationResource, ActionType: framework.Update | framework.Delete}, QueueingHintFn: isAllocationReleased},
+		{Event: framework.ClusterEvent{Resource: leaseResource, ActionType: framework.Delete}, QueueingHintFn: isManagedLeaseDeleted},
+		{Event: framework.ClusterEvent{Resource: gpuClaimResource, ActionType: framework.Add | framework.Update}, QueueingHintFn: isOwnClaimChanged},
+		{Event: framework.ClusterEvent{Resource: framework.Pod, ActionType: framework.Delete}, QueueingHintFn: isGPUPodDeleted},
+	}
+	return append(events, p.podGroupEvents(ctx)...), nil
+}
+
+// isGPUNodeStatusGrown queues when a node reports a new GpuNodeStatus or more
+// usable devices than before, such as a device turning Healthy or the agent
+// no longer reporting a pod on it, or when its GPU links change.
+func (p *Plugin) isGPUNodeStatusGrown(logger klog.Logger, pod *corev1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	oldGNS, newGNS, err := convertPair[apiv1.GpuNodeStatus](oldObj, newObj)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if newGNS == nil {
+		return framework.QueueSkip, nil
+	}
+	if oldGNS == nil || p.usableDevices(newGNS) > p.usableDevices(oldGNS) {
+		logger.V(5).Info("GPUs appeared on node", "pod", klog.KObj(pod), "node", newGNS.Name)
+		return framework.Queue, nil
+	}
+	if !equality.Semantic.DeepEqual(oldGNS.Status.Links, newGNS.Status.Links) {
+		logger.V(5).Info("GPU links changed on node", "pod", klog.KObj(pod), "node", newGNS.Name)
+		return framework.Queue, nil
+	}
+	return framework.QueueSkip, nil
+}
+
+func (p *Plugin) usableDevices(gns *apiv1.GpuNodeStatus) int {
+	free, _, _ := p.availableDevices(gns, nil, nil)
+	return len(free)
+}
+
+// isAllocationReleased queues when a node's ledger drops a GPU.
+func isAllocationReleased(logger klog.Logger, pod *corev1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	oldNA, newNA, err := convertPair[apiv1.GpuNodeAllocation](oldObj, newObj)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if oldNA == nil {
+		return framework.QueueSkip, nil
+	}
+	kept := map[string]map[int]bool{}
+	if newNA != nil {
+		for _, a := range newNA.Spec.Allocations {
+			kept[a.UID] = map[int]bool{}
+			for _, id := range a.GPUIds {
+				kept[a.UID][id] = true
+			}
+		}
+	}
+	for _, a := range oldNA.Spec.Allocations {
+		for _, id := range a.GPUIds {
+			if !kept[a.UID][id] {
+				logger.V(5).Info("GPUs released on node", "pod", klog.KObj(pod), "node", oldNA.Name)
+				return framework.Queue, nil
+			}
+		}
+	}
+	return framework.QueueSkip, nil
+}
+
+// isManagedLeaseDeleted queues when a legacy GPU lease goes away.
+func isManagedLeaseDeleted(_ klog.Logger, _ *corev1.Pod, oldObj, _ interface{}) (framework.QueueingHint, error) {
+	l, err := convert[coordv1.Lease](oldObj)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if l != nil && lease.ManagedSelector().Matches(labels.Set(l.Labels)) {
+		return framework.Queue, nil
+	}
+	return framework.QueueSkip, nil
+}
+
+// isOwnClaimChanged queues when the pod's GpuClaim is created or its spec
+// changes. Status updates by the claim controller are ignored.
+func isOwnClaimChanged(_ klog.Logger, pod *corev1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+	oldClaim, newClaim, err := convertPair[apiv1.GpuClaim](oldObj, newObj)
+	if err != nil {
+		return framework.Queue, err
+	}
+	if newClaim == nil || newClaim.Namespace != pod.Namespace || newClaim.Name != pod.Annotations[util.AnnoClaim] {
+		return framework.QueueSkip, nil
+	}
+	if oldClaim == nil || !equality.Semantic.DeepEqual(oldClaim.Spec, newClaim.Spec) {
+		return framework.Queue, nil
+	}
+	return framework.QueueSkip, nil
+}
+
+// isGPUPodDeleted queues when a pod that was allocated GPUs is deleted. The
+// plugin's cache stops counting its GPUs as soon as the pod is gone.
+func isGPUPodDeleted(_ klog.Logger, _ *corev1.Pod, oldObj, _ interface{}) (framework.QueueingHint, error) {
+	deleted, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return framework.Queue, fmt.Errorf("unexpected object %T for pod delete", oldObj)
+	}
+	if _, held := deleted.Annotations[util.AnnoAllocated]; held {
+		return framework.Queue, nil
+	}
+	return framework.QueueSkip, nil
+}
+
+// convert turns an event object, typed or unstructured from a dynamic
+// informer, into T. A nil object yields nil.
+func convert[T any](obj interface{}) (*T, error) {
+	switch o := obj.(type) {
+	case nil:
+		return nil, nil
+	case *T:
+		return o, nil
+	case *unstructured.Unstructured:
+		out := new(T)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, out); err != nil {
+			return nil, fmt.Errorf("convert %s: %w", o.GetKind(), err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected object %T", obj)
+	}
+}
+
+func convertPair[T any](oldObj, newObj interface{}) (*T, *T, error) {
+	oldT, err := convert[T](oldObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	newT, err := convert[T](newObj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oldT, newT, nil
+}
@@ -0,0 +1,151 @@
+package gpuclaim
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	t.Helper()
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: m}
+}
+
+func TestQueueingHints(t *testing.T) {
+	pod := claimPod("one")
+	grown := newTestPlugin(t).isGPUNodeStatusGrown
+	ledger := func(allocs ...apiv1.PodAllocation) *apiv1.GpuNodeAllocation {
+		return &apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Spec:       apiv1.GpuNodeAllocationSpec{NodeName: "node-a", Allocations: allocs},
+		}
+	}
+	claim := func(name string, count int) *apiv1.GpuClaim {
+		return &apiv1.GpuClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: count}},
+		}
+	}
+	withMessage := claim("one", 1)
+	withMessage.Status.Message = "allocated"
+	allocated := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "done", Annotations: map[string]string{util.AnnoAllocated: "node-a:0"},
+	}}
+	legacy := gpuLease("gpu-system", "node-a", 0)
+	unmanaged := legacy.DeepCopy()
+	unmanaged.Labels = nil
+	a0 := apiv1.PodAllocation{UID: "a", GPUIds: []int{0}}
+	a01 := apiv1.PodAllocation{UID: "a", GPUIds: []int{0, 1}}
+	b1 := apiv1.PodAllocation{UID: "b", GPUIds: []int{1}}
+
+	tests := []struct {
+		name   string
+		hint   framework.QueueingHintFn
+		oldObj interface{}
+		newObj interface{}
+		want   framework.QueueingHint
+	}{
+		{"node status added", grown, nil, toUnstructured(t, nodeStatus("node-a", apiv1.Device{ID: 0})), framework.Queue},
+		{"device recovered", grown,
+			nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1, Health: apiv1.HealthUnhealthy}),
+			nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}), framework.Queue},
+		{"device vacated", grown,
+			nodeStatus("node-a", apiv1.Device{ID: 0, InUseBy: []string{"uid-other"}}),
+			nodeStatus("node-a", apiv1.Device{ID: 0}), framework.Queue},
+		{"device occupied", grown,
+			nodeStatus("node-a", apiv1.Device{ID: 0}),
+			nodeStatus("node-a", apiv1.Device{ID: 0, InUseBy: []string{"uid-other"}}), framework.QueueSkip},
+		{"links changed", grown,
+			nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}),
+			withLinks(nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}), apiv1.Link{A: 0, B: 1, Type: "NVLink"}), framework.Queue},
+		{"heartbeat only", grown,
+			nodeStatus("node-a", apiv1.Device{ID: 0}), nodeStatus("node-a", apiv1.Device{ID: 0}), framework.QueueSkip},
+		{"allocation released", isAllocationReleased, toUnstructured(t, ledger(a0, b1)), toUnstructured(t, ledger(b1)), framework.Queue},
+		{"allocation shrunk", isAllocationReleased, ledger(a01), ledger(a0), framework.Queue},
+		{"allocation deleted", isAllocationReleased, ledger(a0), nil, framework.Queue},
+		{"allocation added", isAllocationReleased, ledger(a0), ledger(a0, b1), framework.QueueSkip},
+		{"managed lease deleted", isManagedLeaseDeleted, legacy, nil, framework.Queue},
+		{"unmanaged lease deleted", isManagedLeaseDeleted, unmanaged, nil, framework.QueueSkip},
+		{"own claim created", isOwnClaimChanged, nil, toUnstructured(t, claim("one", 1)), framework.Queue},
+		{"own claim spec changed", isOwnClaimChanged, claim("one", 2), claim("one", 1), framework.Queue},
+		{"own claim status changed", isOwnClaimChanged, claim("one", 1), withMessage, framework.QueueSkip},
+		{"other claim", isOwnClaimChanged, nil, claim("two", 1), framework.QueueSkip},
+		{"allocated pod deleted", isGPUPodDeleted, allocated, nil, framework.Queue},
+		{"other pod deleted", isGPUPodDeleted, &corev1.Pod{}, nil, framework.QueueSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.hint(klog.Background(), pod, tt.oldObj, tt.newObj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("hint = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventsToRegister(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed bool
+		extra     []framework.EventResource
+	}{
+		{name: "pod group CRDs missing"},
+		{name: "pod group CRDs installed", installed: true, extra: []framework.EventResource{
+			"podgroups.v1beta1.scheduling.volcano.sh",
+			"workloads.v1beta1.kueue.x-k8s.io",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlugin(t)
+			if tt.installed {
+				p.crcClient = crfake.NewClientBuilder().WithRESTMapper(podGroupMapper()).Build()
+			}
+			events, err := p.EventsToRegister(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[framework.EventResource]bool{
+				"gpunodestatuses.v1.gpu.scheduling":    true,
+				"gpunodeallocations.v1.gpu.scheduling": true,
+				"gpuclaims.v1.gpu.scheduling":          true,
+				"leases.v1.coordination.k8s.io":        true,
+				framework.Pod:                          true,
+			}
+			for _, r := range tt.extra {
+				want[r] = true
+			}
+			for _, e := range events {
+				if !want[e.Event.Resource] {
+					t.Errorf("unexpected event resource %q", e.Event.Resource)
+				}
+				delete(want, e.Event.Resource)
+			}
+			if len(want) != 0 {
+				t.Errorf("missing events for %v", want)
+			}
+		})
+	}
+}
+
+func withLinks(gns *apiv1.GpuNodeStatus, links ...apiv1.Link) *apiv1.GpuNodeStatus {
+	gns.Status.Links = links
+	return gns
+}
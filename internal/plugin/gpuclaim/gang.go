@@ -0,0 +1,134 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+// defaultGangTimeout bounds how long reserved members wait for the rest of
+// their gang before all of them are rejected and release their GPUs.
+const defaultGangTimeout = 60 * time.Second
+
+// waitingPodLister is the part of framework.Handle used for gang scheduling.
+type waitingPodLister interface {
+	IterateOverWaitingPods(callback func(framework.WaitingPod))
+}
+
+// gang identifies pods whose claims share a GangRef within a namespace.
+type gang struct {
+	namespace string
+	name      string
+	size      int
+}
+
+func (g *gang) String() string { return g.namespace + "/" + g.name }
+
+// gangOf returns the gang of a pod from its claim, or nil if it has none.
+// The claim's gangSize wins over the pod's gang-size annotation; gangs backed
+// by an external pod group are sized later by resolvePodGroup.
+func gangOf(pod *corev1.Pod, claim *apiv1.GpuClaim) (*gang, error) {
+	if claim.Spec.GangRef == "" {
+		return nil, nil
+	}
+	g := &gang{namespace: pod.Namespace, name: claim.Spec.GangRef, size: claim.Spec.GangSize}
+	if raw := pod.Annotations[util.AnnoGangSize]; raw != "" && g.size <= 0 {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q", util.AnnoGangSize, raw)
+		}
+		g.size = n
+	}
+	return g, nil
+}
+
+// Permit holds a gang member that has reserved GPUs until the whole gang has,
+// then releases every waiting member at once.
+func (p *Plugin) Permit(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, nodeName string) (*framework.Status, time.Duration) {
+	data, err := readState(cycleState)
+	if err != nil {
+		return framework.AsStatus(err), 0
+	}
+	g := data.gang
+	if g == nil || g.size <= 1 {
+		return nil, 0
+	}
+
+	waiting := p.waitingMembers(ctx, pod.UID, g)
+	running, err := p.runningMembers(ctx, pod.UID, g)
+	if err != nil {
+		return framework.AsStatus(err), 0
+	}
+	if ready := 1 + len(waiting) + running; ready < g.size {
+		msg := fmt.Sprintf("waiting for gang %s (%d/%d members reserved)", g, ready, g.size)
+		return framework.NewStatus(framework.Wait, msg), p.gangTimeout
+	}
+
+	klog.V(4).InfoS("gang complete, allowing members", "gang", g.String(), "size", g.size)
+	for _, wp := range waiting {
+		wp.Allow(Name)
+	}
+	return nil, 0
+}
+
+// rejectGang rejects the waiting members of a gang once one member fails, so
+// none of them keep GPUs for a job that cannot start.
+func (p *Plugin) rejectGang(ctx context.Context, pod *corev1.Pod, g *gang) {
+	for _, wp := range p.waitingMembers(ctx, pod.UID, g) {
+		wp.Reject(Name, fmt.Sprintf("gang %s member %s was rejected", g, pod.Name))
+	}
+}
+
+// waitingMembers returns the other pods of the gang waiting in Permit.
+func (p *Plugin) waitingMembers(ctx context.Context, self types.UID, g *gang) []framework.WaitingPod {
+	var out []framework.WaitingPod
+	p.waiting.IterateOverWaitingPods(func(wp framework.WaitingPod) {
+		if other := wp.GetPod(); other.UID != self && p.inGang(ctx, other, g) {
+			out = append(out, wp)
+		}
+	})
+	return out
+}
+
+// runningMembers counts the other pods of the gang already bound to a node.
+func (p *Plugin) runningMembers(ctx context.Context, self types.UID, g *gang) (int, error) {
+	pods := &corev1.PodList{}
+	if err := p.crcClient.List(ctx, pods, crclient.InNamespace(g.namespace)); err != nil {
+		return 0, fmt.Errorf("list pods of gang %s: %w", g, err)
+	}
+	n := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.UID == self || pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil ||
+			pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if p.inGang(ctx, pod, g) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// inGang reports whether the pod's claim belongs to the gang.
+func (p *Plugin) inGang(ctx context.Context, pod *corev1.Pod, g *gang) bool {
+	name := pod.Annotations[util.AnnoClaim]
+	if name == "" || pod.Namespace != g.namespace {
+		return false
+	}
+	claim := &apiv1.GpuClaim{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: name}, claim); err != nil {
+		return false
+	}
+	return claim.Spec.GangRef == g.name
+}
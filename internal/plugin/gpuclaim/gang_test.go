@@ -0,0 +1,172 @@
+package gpuclaim
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+// fakeWaitingPod records the verdict given to a pod waiting in Permit.
+type fakeWaitingPod struct {
+	pod      *corev1.Pod
+	allowed  bool
+	rejected string
+}
+
+func (w *fakeWaitingPod) GetPod() *corev1.Pod         { return w.pod }
+func (w *fakeWaitingPod) GetPendingPlugins() []string { return []string{Name} }
+func (w *fakeWaitingPod) Allow(string)                { w.allowed = true }
+func (w *fakeWaitingPod) Reject(_ string, msg string) { w.rejected = msg }
+func (w *fakeWaitingPod) verdict() (bool, bool)       { return w.allowed, w.rejected != "" }
+func (w *fakeWaitingPod) String() string              { return w.pod.Name }
+
+// fakeWaitingPods stands in for the framework's waiting pods map.
+type fakeWaitingPods map[types.UID]*fakeWaitingPod
+
+func (f fakeWaitingPods) IterateOverWaitingPods(cb func(framework.WaitingPod)) {
+	for _, w := range f {
+		cb(w)
+	}
+}
+
+func gangPod(name, claim string) *corev1.Pod {
+	pod := claimPod(claim)
+	pod.Name, pod.UID = name, types.UID("uid-"+name)
+	return pod
+}
+
+func TestPermitGang(t *testing.T) {
+	ctx := context.Background()
+	claim := func(name string, size int) *apiv1.GpuClaim {
+		return &apiv1.GpuClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}, GangRef: "job", GangSize: size},
+		}
+	}
+	// A member of the same gang already running on another node.
+	running := gangPod("running", "worker")
+	running.Spec.NodeName = "node-b"
+	running.Status.Phase = corev1.PodRunning
+
+	tests := []struct {
+		name     string
+		claims   []*apiv1.GpuClaim
+		objs     []*corev1.Pod
+		pods     []*corev1.Pod
+		wantWait []bool
+	}{
+		{
+			name:     "size from claim",
+			claims:   []*apiv1.GpuClaim{claim("worker", 2)},
+			pods:     []*corev1.Pod{gangPod("w0", "worker"), gangPod("w1", "worker")},
+			wantWait: []bool{true, false},
+		},
+		{
+			name:   "size from annotation across claims",
+			claims: []*apiv1.GpuClaim{claim("launcher", 0), claim("worker", 0)},
+			pods: func() []*corev1.Pod {
+				l, w := gangPod("l0", "launcher"), gangPod("w0", "worker")
+				l.Annotations[util.AnnoGangSize] = "2"
+				w.Annotations[util.AnnoGangSize] = "2"
+				return []*corev1.Pod{l, w}
+			}(),
+			wantWait: []bool{true, false},
+		},
+		{
+			name:     "running members count",
+			claims:   []*apiv1.GpuClaim{claim("worker", 2)},
+			objs:     []*corev1.Pod{running},
+			pods:     []*corev1.Pod{gangPod("w0", "worker")},
+			wantWait: []bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1})}
+			for _, c := range tt.claims {
+				objs = append(objs, c)
+			}
+			p := newTestPlugin(t, objs...)
+			for _, pod := range tt.objs {
+				if err := p.crcClient.Create(ctx, pod.DeepCopy()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waiting := fakeWaitingPods{}
+			p.waiting = waiting
+
+			for i, pod := range tt.pods {
+				state := framework.NewCycleState()
+				if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+					t.Fatalf("PreFilter(%s): %v", pod.Name, st)
+				}
+				if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+					t.Fatalf("Reserve(%s): %v", pod.Name, st)
+				}
+				st, timeout := p.Permit(ctx, state, pod, "node-a")
+				if got := st.Code() == framework.Wait; got != tt.wantWait[i] {
+					t.Fatalf("Permit(%s) = %v, want wait=%v", pod.Name, st, tt.wantWait[i])
+				}
+				if st.Code() == framework.Wait {
+					if timeout != defaultGangTimeout {
+						t.Errorf("timeout = %v, want %v", timeout, defaultGangTimeout)
+					}
+					waiting[pod.UID] = &fakeWaitingPod{pod: pod}
+				}
+			}
+			for _, w := range waiting {
+				if allowed, rejected := w.verdict(); !allowed || rejected {
+					t.Errorf("waiting pod %s allowed=%v rejected=%v, want allowed", w, allowed, rejected)
+				}
+			}
+		})
+	}
+}
+
+func TestUnreserveRejectsGang(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}, GangRef: "job", GangSize: 3},
+	}
+	p := newTestPlugin(t, claim, nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}, apiv1.Device{ID: 2}))
+	waiting := fakeWaitingPods{}
+	p.waiting = waiting
+
+	first, second := gangPod("w0", "worker"), gangPod("w1", "worker")
+	var secondState *framework.CycleState
+	for _, pod := range []*corev1.Pod{first, second} {
+		state := framework.NewCycleState()
+		if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+			t.Fatalf("PreFilter(%s): %v", pod.Name, st)
+		}
+		if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+			t.Fatalf("Reserve(%s): %v", pod.Name, st)
+		}
+		if st, _ := p.Permit(ctx, state, pod, "node-a"); st.Code() != framework.Wait {
+			t.Fatalf("Permit(%s) = %v, want Wait", pod.Name, st)
+		}
+		waiting[pod.UID] = &fakeWaitingPod{pod: pod}
+		secondState = state
+	}
+
+	// The second member times out: the framework drops it from the waiting
+	// pods and runs Unreserve, which must reject the rest of the gang.
+	delete(waiting, second.UID)
+	p.Unreserve(ctx, secondState, second, "node-a")
+
+	if _, rejected := waiting[first.UID].verdict(); !rejected {
+		t.Errorf("first member not rejected")
+	}
+	if p.gpus.snapshot().Has("node-a", 1) {
+		t.Errorf("second member's GPU still held after Unreserve")
+	}
+}
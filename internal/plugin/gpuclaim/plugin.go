@@ -4,22 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	coordv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	clientset "k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
-	coordclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/helper"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/controllers"
+	"github.com/ziwon/gpu-scheduler/internal/alloc"
 	"github.com/ziwon/gpu-scheduler/internal/lease"
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+	_ "github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config/scheme"
+	"github.com/ziwon/gpu-scheduler/internal/topo"
 	"github.com/ziwon/gpu-scheduler/internal/util"
 )
 
@@ -27,41 +41,124 @@ const (
 	// Name exposes the plugin identifier to the framework.
 	Name = "GpuClaimPlugin"
 
-	defaultGPUCount = 1
-	maxGPUID        = 16 // MVP assumption: at most 17 devices per host. Can be 64 with virtual GPUs on NVIDIA H200, B200
+	// cacheSyncTimeout bounds how long New waits for its informers, so a
+	// missing CRD or RBAC rule fails startup instead of hanging it.
+	cacheSyncTimeout = 2 * time.Minute
 )
 
 var (
-	_ framework.PreFilterPlugin = &Plugin{}
-	_ framework.FilterPlugin    = &Plugin{}
-	_ framework.ScorePlugin     = &Plugin{}
-	_ framework.ReservePlugin   = &Plugin{}
-	_ framework.PreBindPlugin   = &Plugin{}
-	_ framework.StateData       = &stateData{}
+	_ framework.PreFilterPlugin     = &Plugin{}
+	_ framework.PreFilterExtensions = &Plugin{}
+	_ framework.FilterPlugin        = &Plugin{}
+	_ framework.PostFilterPlugin    = &Plugin{}
+	_ framework.ScorePlugin         = &Plugin{}
+	_ framework.ScoreExtensions     = &Plugin{}
+	_ framework.ReservePlugin       = &Plugin{}
+	_ framework.PermitPlugin        = &Plugin{}
+	_ framework.PreBindPlugin       = &Plugin{}
+	_ framework.StateData           = &stateData{}
 )
 
 // stateData is stored in CycleState.
 type stateData struct {
 	claimName  string
+	claim      *apiv1.GpuClaim
+	selector   labels.Selector
 	reqCount   int
 	chosenIDs  []int
 	chosenNode string
+	// chosenBandwidth is the lowest GB/s among chosenIDs.
+	chosenBandwidth int
+	// claim, selector and gang are captured in PreFilter and read-only
+	// afterwards, so clones share them.
+	gang *gang
+	// held is this cycle's snapshot of allocated GPUs. AddPod and RemovePod
+	// adjust it on a clone during preemption and nominated-pod evaluation,
+	// so clones copy it; removed keeps the ids of pods RemovePod took out.
+	held    alloc.Set
+	removed map[types.UID][]int
+
+	// picks records the best device set per node computed by Filter/Score.
+	// Filter and Score run concurrently across nodes, hence the mutex.
+	mu    sync.Mutex
+	picks map[string]nodePick
+}
+
+// nodePick is a device set selected on one node. usage is the node's GPU
+// usage score under the configured scoring strategy, and wholeness how
+// unfragmented the pick leaves the node's free GPUs.
+type nodePick struct {
+	ids       []int
+	bandwidth int
+	usage     int64
+	wholeness int64
 }
 
 func (s *stateData) Clone() framework.StateData {
 	if s == nil {
 		return nil
 	}
-	out := *s
-	out.chosenIDs = append([]int(nil), s.chosenIDs...)
-	return &out
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := &stateData{
+		claimName:       s.claimName,
+		claim:           s.claim,
+		selector:        s.selector,
+		reqCount:        s.reqCount,
+		chosenIDs:       append([]int(nil), s.chosenIDs...),
+		chosenNode:      s.chosenNode,
+		chosenBandwidth: s.chosenBandwidth,
+		gang:            s.gang,
+		held:            s.held.Clone(),
+		removed:         make(map[types.UID][]int, len(s.removed)),
+		picks:           make(map[string]nodePick, len(s.picks)),
+	}
+	for uid, ids := range s.removed {
+		out.removed[uid] = ids
+	}
+	for node, pick := range s.picks {
+		out.picks[node] = pick
+	}
+	return out
+}
+
+func (s *stateData) setPick(node string, pick nodePick) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.picks[node] = pick
+}
+
+func (s *stateData) pick(node string) nodePick {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.picks[node]
+}
+
+// released returns the pods RemovePod took out of this cycle's snapshot, whose
+// GPUs count as free even while the agent still reports them in use.
+func (s *stateData) released() map[types.UID]bool {
+	if len(s.removed) == 0 {
+		return nil
+	}
+	out := make(map[types.UID]bool, len(s.removed))
+	for uid := range s.removed {
+		out[uid] = true
+	}
+	return out
 }
 
 // Plugin implements scheduler hooks.
 type Plugin struct {
-	client    clientset.Interface
-	coord     coordclient.CoordinationV1Interface
-	crcClient crclient.Client
+	client      clientset.Interface
+	crcClient   crclient.Client
+	gpus        *gpuCache
+	waiting     waitingPodLister
+	preempt     preemptionHandle
+	gangTimeout time.Duration
+	args        config.GpuClaimPluginArgs
+	// usageScore scores GPU usage for the configured strategy; nil when
+	// nodes are ranked on topology alone.
+	usageScore func(requested, capacity int) int64
 }
 
 // Name satisfies framework.Plugin interface.
@@ -75,9 +172,17 @@ func (p *Plugin) Name() string { return Name }
 // 	}, nil
 // }
 
-func New(_ context.Context, _ runtime.Object, handle framework.Handle) (framework.Plugin, error) {
+func New(ctx context.Context, obj runtime.Object, handle framework.Handle) (framework.Plugin, error) {
 	cs := handle.ClientSet()
 
+	args, ok := obj.(*config.GpuClaimPluginArgs)
+	if !ok {
+		return nil, fmt.Errorf("want args of type GpuClaimPluginArgs, got %T", obj)
+	}
+	if err := config.ValidateGpuClaimPluginArgs(field.NewPath("args"), args); err != nil {
+		return nil, err
+	}
+
 	scheme := runtime.NewScheme()
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(apiv1.AddToScheme(scheme))
@@ -87,21 +192,91 @@ func New(_ context.Context, _ runtime.Object, handle framework.Handle) (framewor
 		return nil, fmt.Errorf("build kube config: %v", err)
 	}
 
-	c, err := crclient.New(cfg, crclient.Options{Scheme: scheme})
+	mgr, err := newManager(cfg, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claims and node status are read from the shared informers. The ledger is
+	// always read live: its writes are conditional on the resourceVersion read.
+	c, err := crclient.New(cfg, crclient.Options{
+		Scheme: scheme,
+		Cache: &crclient.CacheOptions{
+			Reader:     mgr.GetCache(),
+			DisableFor: []crclient.Object{&apiv1.GpuNodeAllocation{}, &coordv1.Lease{}},
+		},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("build controller-runtime client: %v", err)
 	}
 
+	gpus := newGPUCache()
+	if err := gpus.register(ctx, mgr.GetCache()); err != nil {
+		return nil, err
+	}
+	if err := gpus.watchPods(handle.SharedInformerFactory().Core().V1().Pods().Informer()); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			klog.ErrorS(err, "GpuClaim controller stopped")
+		}
+	}()
+	if err := waitForCaches(ctx, gpus, mgr.GetCache()); err != nil {
+		return nil, err
+	}
+
 	// Start the garbage collector
-	lease.StartGC(context.Background(), cs)
+	alloc.StartGC(ctx, c, mgr.GetAPIReader(), args.GCInterval.Duration)
 
 	return &Plugin{
-		client:    cs,
-		coord:     cs.CoordinationV1(),
-		crcClient: c,
+		client:      cs,
+		crcClient:   c,
+		gpus:        gpus,
+		waiting:     handle,
+		preempt:     handle,
+		gangTimeout: defaultGangTimeout,
+		args:        *args,
+		usageScore:  usageScorer(args.ScoringStrategy),
 	}, nil
 }
 
+// waitForCaches waits up to cacheSyncTimeout for the GPU state informers and
+// the rest of the manager's cache to sync.
+func waitForCaches(ctx context.Context, gpus *gpuCache, c cache.Cache) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, cacheSyncTimeout,
+		fmt.Errorf("timed out after %s; check that the CRDs are installed and the scheduler may list them", cacheSyncTimeout))
+	defer cancel()
+	if err := gpus.waitForSync(ctx, c); err != nil {
+		return err
+	}
+	if !c.WaitForCacheSync(ctx) {
+		return fmt.Errorf("GpuClaim controller informers did not sync: %w", context.Cause(ctx))
+	}
+	return nil
+}
+
+// newManager builds the controller-runtime manager whose cache backs the
+// plugin's informers and which hosts the GpuClaim reconciler. Its metrics
+// endpoint is disabled so it does not collide with the scheduler's own.
+func newManager(cfg *rest.Config, scheme *runtime.Scheme) (ctrl.Manager, error) {
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		Cache: cache.Options{ByObject: map[crclient.Object]cache.ByObject{
+			&coordv1.Lease{}: {Label: lease.ManagedSelector()},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build controller manager: %v", err)
+	}
+	if err := (&controllers.GpuClaimReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		return nil, fmt.Errorf("setup GpuClaim controller: %v", err)
+	}
+	return mgr, nil
+}
+
 // PreFilter reads annotations and seeds scheduler state.
 func (p *Plugin) PreFilter(
 	ctx context.Context,
@@ -124,35 +299,191 @@ func (p *Plugin) PreFilter(
 		return nil, framework.NewStatus(framework.Unschedulable, msg)
 	}
 
-	// Use devices.count, default to defaultGPUCount if not specified
+	// Use devices.count, default to the configured defaultGPUCount if not specified
 	reqCount := claim.Spec.Devices.Count
 	if reqCount <= 0 {
-		reqCount = defaultGPUCount
+		reqCount = p.args.DefaultGPUCount
+	}
+
+	selector := labels.Everything()
+	if claim.Spec.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(claim.Spec.Selector); err != nil {
+			msg := fmt.Sprintf("invalid selector in GpuClaim %q: %v", claimName, err)
+			return nil, framework.NewStatus(framework.UnschedulableAndUnresolvable, msg)
+		}
+	}
+
+	g, err := gangOf(pod, claim)
+	if err != nil {
+		return nil, framework.NewStatus(framework.UnschedulableAndUnresolvable, err.Error())
+	}
+	if g != nil {
+		if st := p.resolvePodGroup(ctx, g); !st.IsSuccess() {
+			return nil, st
+		}
 	}
 
 	state := &stateData{
 		claimName: claimName,
+		claim:     claim,
+		selector:  selector,
 		reqCount:  reqCount,
+		held:      p.gpus.snapshot(),
+		removed:   map[types.UID][]int{},
+		gang:      g,
+		picks:     map[string]nodePick{},
 	}
 	cycleState.Write(Name, state)
 	return nil, nil
 }
 
-func (p *Plugin) PreFilterExtensions() framework.PreFilterExtensions { return nil }
+func (p *Plugin) PreFilterExtensions() framework.PreFilterExtensions { return p }
+
+// AddPod marks the GPUs of a pod the framework adds to the node as held in
+// this cycle's snapshot. A nominated pod holds none yet, so it is assumed to
+// take the devices its own claim would pick.
+func (p *Plugin) AddPod(ctx context.Context, cycleState *framework.CycleState, _ *corev1.Pod, podInfoToAdd *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
+	data, err := readState(cycleState)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	node, pod := nodeInfo.Node().Name, podInfoToAdd.Pod
+
+	ids, ok := data.removed[pod.UID]
+	if ok {
+		delete(data.removed, pod.UID)
+	} else if ids = data.held.Drop(node, pod.UID); len(ids) == 0 {
+		ids = p.simulatePick(ctx, pod, node, data.held)
+	}
+	for _, id := range ids {
+		data.held.Add(node, id, pod.UID)
+	}
+	return nil
+}
+
+// RemovePod frees the GPUs a pod holds on the node in this cycle's snapshot.
+func (p *Plugin) RemovePod(_ context.Context, cycleState *framework.CycleState, _ *corev1.Pod, podInfoToRemove *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status {
+	data, err := readState(cycleState)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	pod := podInfoToRemove.Pod
+	if ids := data.held.Drop(nodeInfo.Node().Name, pod.UID); len(ids) > 0 {
+		data.removed[pod.UID] = ids
+	}
+	return nil
+}
+
+// simulatePick returns the devices a pod without an allocation would take
+// on node, or nil if it has no claim or would not fit.
+func (p *Plugin) simulatePick(ctx context.Context, pod *corev1.Pod, node string, held alloc.Set) []int {
+	name := pod.Annotations[util.AnnoClaim]
+	gns, ok := p.gpus.nodeStatus(node)
+	if name == "" || !ok {
+		return nil
+	}
+	claim := &apiv1.GpuClaim{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: name}, claim); err != nil {
+		return nil
+	}
+	data := &stateData{claim: claim, reqCount: claim.Spec.Devices.Count}
+	if data.reqCount <= 0 {
+		data.reqCount = p.args.DefaultGPUCount
+	}
+	free, _, _ := p.availableDevices(gns, held, nil)
+	if len(free) < data.reqCount {
+		return nil
+	}
+	_, pick, _ := pickDevices(free, gns.Status.Links, data)
+	return pick.ids
+}
 
-// Filter enforces node selector requirements (noop for MVP).
+// Filter rejects nodes outside the claim's selector and nodes that cannot supply
+// the requested number of healthy, unallocated GPUs.
 func (p *Plugin) Filter(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	data, err := readState(cycleState)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	node := nodeInfo.Node()
+	if node == nil {
+		return framework.NewStatus(framework.Error, "node not found")
+	}
+	if !data.selector.Matches(labels.Set(node.Labels)) {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, "node does not match GpuClaim selector")
+	}
+
+	gns, ok := p.gpus.nodeStatus(node.Name)
+	if !ok {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, "node has no GpuNodeStatus")
+	}
+
+	free, allocated, excluded := p.availableDevices(gns, data.held, data.released())
+	if len(free) < data.reqCount {
+		msg := fmt.Sprintf("insufficient free GPUs (requested=%d, free=%d, allocated=%d, total=%d)%s",
+			data.reqCount, len(free), allocated, len(gns.Status.Devices), excludedSuffix(excluded))
+		return framework.NewStatus(framework.Unschedulable, msg)
+	}
+	_, pick, ok := pickDevices(free, gns.Status.Links, data)
+	if !ok {
+		return framework.NewStatus(framework.Unschedulable, unsatisfiedMessage(data, len(free), excluded))
+	}
+	data.setPick(node.Name, pick)
 	return nil
 }
 
-// Score favors nodes with contiguous GPUs. MVP stub returns static score.
+// Score ranks nodes by how well their free GPUs fit the claim's device policy.
+// Raw scores are unbounded and mapped onto [0, MaxNodeScore] by NormalizeScore.
+// Unless the strategy is Topology, the node's GPU usage score is recorded
+// with the pick for NormalizeScore to blend in.
 func (p *Plugin) Score(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, nodeInfo *framework.NodeInfo) (int64, *framework.Status) {
-	return 1, nil
+	data, err := readState(cycleState)
+	if err != nil {
+		return 0, framework.AsStatus(err)
+	}
+	node := nodeInfo.Node()
+	if node == nil {
+		return 0, framework.NewStatus(framework.Error, "node not found")
+	}
+
+	gns, ok := p.gpus.nodeStatus(node.Name)
+	if !ok {
+		return 0, framework.NewStatus(framework.Error, "node has no GpuNodeStatus")
+	}
+	free, allocated, _ := p.availableDevices(gns, data.held, data.released())
+	score, pick, ok := pickDevices(free, gns.Status.Links, data)
+	if !ok {
+		return 0, nil
+	}
+	if p.usageScore != nil {
+		pick.usage = p.usageScore(allocated+data.reqCount, allocated+len(free))
+	}
+	pick.wholeness = wholeness(free, pick.ids)
+	data.setPick(node.Name, pick)
+	return score, nil
 }
 
-func (p *Plugin) ScoreExtensions() framework.ScoreExtensions { return nil }
+func (p *Plugin) ScoreExtensions() framework.ScoreExtensions { return p }
 
-// Reserve acquires GPU leases on the chosen node.
+// NormalizeScore scales raw topology scores onto the framework's node score
+// range, then blends in the fragmentation scores and, unless the strategy is
+// Topology, the usage scores.
+func (p *Plugin) NormalizeScore(_ context.Context, cycleState *framework.CycleState, _ *corev1.Pod, scores framework.NodeScoreList) *framework.Status {
+	if st := helper.DefaultNormalizeScore(framework.MaxNodeScore, false, scores); !st.IsSuccess() {
+		return st
+	}
+	data, err := readState(cycleState)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	combineScores(data, p.args.ScoringStrategy, p.usageScore != nil, scores)
+	return nil
+}
+
+// Reserve records the device set picked on the chosen node in the node's
+// GpuNodeAllocation. If another pod took one of the GPUs in the meantime, the
+// pick is recomputed once against fresh state before giving up.
 func (p *Plugin) Reserve(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, nodeName string) *framework.Status {
 	data, err := readState(cycleState)
 	if err != nil {
@@ -160,64 +491,77 @@ func (p *Plugin) Reserve(ctx context.Context, cycleState *framework.CycleState,
 	}
 	data.chosenNode = nodeName
 
-	// Fetch the GpuNodeStatus to see available devices.
-	gns, err := p.getGpuNodeStatus(ctx, nodeName)
-	if err != nil {
-		return framework.NewStatus(framework.Error, fmt.Sprintf("get GpuNodeStatus: %v", err))
+	if pick := data.pick(nodeName); len(pick.ids) == data.reqCount {
+		err := p.acquire(ctx, pod, nodeName, pick.ids)
+		if err == nil {
+			data.chosenIDs, data.chosenBandwidth = pick.ids, pick.bandwidth
+			return nil
+		}
+		klog.V(4).InfoS("scored GPU pick no longer available, recomputing", "node", nodeName, "gpuIDs", pick.ids, "err", err)
 	}
 
-	// Check if the node has any GPU devices.
-	if len(gns.Status.Devices) == 0 {
-		return framework.NewStatus(framework.Unschedulable, "node has no GPU devices")
+	pick, status := p.repick(ctx, data, nodeName)
+	if !status.IsSuccess() {
+		return status
 	}
+	if err := p.acquire(ctx, pod, nodeName, pick.ids); err != nil {
+		msg := fmt.Sprintf("acquire GPUs %v on node %s: %v", pick.ids, nodeName, err)
+		return framework.NewStatus(framework.Unschedulable, msg)
+	}
+	data.chosenIDs, data.chosenBandwidth = pick.ids, pick.bandwidth
+	data.setPick(nodeName, pick)
+	return nil
+}
 
-	// Try to acquire leases for the requested GPU count.
-	var allocated []int
-	for _, dev := range gns.Status.Devices {
-		if len(allocated) >= data.reqCount {
-			break
-		}
-
-		id := dev.ID
-		ok, err := lease.TryAcquire(ctx, p.coord, pod.Namespace, nodeName, string(pod.UID), pod.Name, id)
-		if err != nil {
-			klog.V(4).InfoS("lease acquisition failed", "node", nodeName, "gpuID", id, "err", err)
-			continue
-		}
-		if ok {
-			allocated = append(allocated, id)
-		}
+// repick recomputes a policy-compliant device set from the live ledger.
+func (p *Plugin) repick(ctx context.Context, data *stateData, nodeName string) (nodePick, *framework.Status) {
+	gns, ok := p.gpus.nodeStatus(nodeName)
+	if !ok {
+		return nodePick{}, framework.NewStatus(framework.Error, "node has no GpuNodeStatus")
+	}
+	held, err := alloc.Held(ctx, p.crcClient)
+	if err != nil {
+		return nodePick{}, framework.NewStatus(framework.Error, err.Error())
 	}
 
-	// Check if we acquired enough GPUs.
-	if len(allocated) < data.reqCount {
-		total := len(gns.Status.Devices)
-		free := 0
-		for _, dev := range gns.Status.Devices {
-			_ = dev
-		}
-		klog.V(4).InfoS("not enough GPUs available", "node", nodeName, "requested", data.reqCount, "allocated", len(allocated), "free", free, "total", total)
-		// Release any partial allocations.
-		for _, id := range allocated {
-			_ = lease.Release(ctx, p.coord, pod.Namespace, nodeName, id)
-		}
-		msg := fmt.Sprintf("not enough GPUs available on node %s (requested=%d, total=%d)", nodeName, data.reqCount, total)
-		return framework.NewStatus(framework.Unschedulable, msg)
+	free, _, excluded := p.availableDevices(gns, held, nil)
+	if len(free) < data.reqCount {
+		msg := fmt.Sprintf("not enough GPUs available on node %s (requested=%d, free=%d, total=%d)%s",
+			nodeName, data.reqCount, len(free), len(gns.Status.Devices), excludedSuffix(excluded))
+		return nodePick{}, framework.NewStatus(framework.Unschedulable, msg)
+	}
+	_, pick, ok := pickDevices(free, gns.Status.Links, data)
+	if !ok {
+		return nodePick{}, framework.NewStatus(framework.Unschedulable, unsatisfiedMessage(data, len(free), excluded))
 	}
+	return pick, nil
+}
 
-	data.chosenIDs = allocated
-	cycleState.Write(Name, data)
+// acquire claims every id or none of them with a single ledger write.
+func (p *Plugin) acquire(ctx context.Context, pod *corev1.Pod, nodeName string, ids []int) error {
+	// Assume first: the informer may deliver the write before Acquire returns,
+	// and only an assumption already in place is cleared by that event.
+	p.gpus.assume(pod.UID, nodeName, ids)
+	if err := alloc.Acquire(ctx, p.crcClient, nodeName, pod, ids); err != nil {
+		p.gpus.forget(pod.UID, nodeName)
+		return err
+	}
 	return nil
 }
 
-// Unreserve releases leases when scheduling fails.
+// Unreserve releases the pod's GPUs when scheduling fails, and rejects the
+// waiting members of its gang so they release theirs too.
 func (p *Plugin) Unreserve(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, nodeName string) {
 	data, err := readState(cycleState)
 	if err != nil {
 		return
 	}
-	for _, id := range data.chosenIDs {
-		_ = lease.Release(ctx, p.coord, pod.Namespace, nodeName, id)
+	if data.gang != nil {
+		p.rejectGang(ctx, pod, data.gang)
+	}
+	p.gpus.forget(pod.UID, nodeName)
+	if err := alloc.Release(ctx, p.crcClient, nodeName, pod.UID); err != nil {
+		klog.ErrorS(err, "failed to release GPUs", "pod", klog.KObj(pod), "node", nodeName)
 	}
 }
 
@@ -244,15 +588,157 @@ func (p *Plugin) PreBind(ctx context.Context, cycleState *framework.CycleState,
 	if _, err := p.client.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, b, metav1.PatchOptions{}); err != nil {
 		return framework.NewStatus(framework.Error, fmt.Sprintf("patch pod annotations: %v", err))
 	}
+
+	// The claim message is informational; failing to write it must not block binding.
+	msg := fmt.Sprintf("allocated GPUs %v on %s (bandwidth %d GB/s)", data.chosenIDs, nodeName, data.chosenBandwidth)
+	if err := p.setClaimMessage(ctx, pod.Namespace, data.claimName, msg); err != nil {
+		klog.V(4).InfoS("failed to update GpuClaim status message", "claim", data.claimName, "err", err)
+	}
 	return nil
 }
 
-func (p *Plugin) getGpuNodeStatus(ctx context.Context, nodeName string) (*apiv1.GpuNodeStatus, error) {
-	gns := &apiv1.GpuNodeStatus{}
-	if err := p.crcClient.Get(ctx, types.NamespacedName{Name: nodeName}, gns); err != nil {
-		return nil, err
+func (p *Plugin) setClaimMessage(ctx context.Context, ns, name, msg string) error {
+	claim := &apiv1.GpuClaim{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, claim); err != nil {
+		return err
+	}
+	patch := crclient.MergeFrom(claim.DeepCopy())
+	claim.Status.Message = msg
+	return p.crcClient.Status().Patch(ctx, claim, patch)
+}
+
+// availableDevices splits the node's devices into those free for allocation, a
+// count of those in use, and a note on each device excluded for its health or
+// because the agent reports a pod on it. Besides the ledger, the agent's InUseBy
+// is authoritative occupancy, except for pods in released. Devices above the
+// configured maxGPUID are ignored.
+func (p *Plugin) availableDevices(gns *apiv1.GpuNodeStatus, held alloc.Set, released map[types.UID]bool) (free []apiv1.Device, allocated int, excluded []string) {
+	for _, dev := range gns.Status.Devices {
+		if dev.ID > p.args.MaxGPUID {
+			continue
+		}
+		if reason := p.healthExclusion(dev.Health); reason != "" {
+			excluded = append(excluded, fmt.Sprintf("%d (%s)", dev.ID, reason))
+			continue
+		}
+		if held.Has(gns.Name, dev.ID) {
+			allocated++
+			continue
+		}
+		if uid := occupant(dev, released); uid != "" {
+			allocated++
+			excluded = append(excluded, fmt.Sprintf("%d (in use by %s)", dev.ID, uid))
+			continue
+		}
+		free = append(free, dev)
+	}
+	return free, allocated, excluded
+}
+
+// healthExclusion explains why a device with the given health is not
+// allocated, or returns "" if it is. Only Healthy devices are, plus those of
+// Unknown or unreported health unless the args exclude them.
+func (p *Plugin) healthExclusion(health string) string {
+	switch health {
+	case apiv1.HealthHealthy:
+		return ""
+	case apiv1.HealthUnknown, "":
+		if p.args.UnknownHealth == config.ExcludeUnknown {
+			return "health Unknown"
+		}
+		return ""
+	}
+	return "health " + health
+}
+
+// occupant returns the first pod the agent reports on the device that was not
+// released, or "" if there is none.
+func occupant(dev apiv1.Device, released map[types.UID]bool) string {
+	for _, uid := range dev.InUseBy {
+		if !released[types.UID(uid)] {
+			return uid
+		}
+	}
+	return ""
+}
+
+// excludedSuffix appends excluded devices to an unschedulable message.
+func excludedSuffix(excluded []string) string {
+	if len(excluded) == 0 {
+		return ""
+	}
+	return "; excluded GPUs: " + strings.Join(excluded, ", ")
+}
+
+// deviceInfos converts API devices into the topology package's representation.
+func deviceInfos(devs []apiv1.Device) []topo.DeviceInfo {
+	out := make([]topo.DeviceInfo, len(devs))
+	for i, d := range devs {
+		out[i] = topo.DeviceInfo{ID: d.ID, Island: d.Island, PCIeRoot: d.PCIeRoot, Bandwidth: d.Bandwidth}
+	}
+	return out
+}
+
+// topoRequest translates the claim's device and topology requests for the policy engine.
+func topoRequest(spec apiv1.GpuClaimSpec, count int) topo.Request {
+	req := topo.Request{
+		Count:           count,
+		Policy:          spec.Devices.Policy,
+		PreferIDs:       spec.Devices.PreferIDs,
+		StrictPreferIDs: spec.Devices.StrictPreferIDs,
+	}
+	if spec.Topology != nil {
+		req.Topology = spec.Topology.Mode
+		req.MinBandwidth = spec.Topology.MinBandwidthGBps
+	}
+	return req
+}
+
+// linkGraph builds the pairwise link graph of the free devices, or nil when
+// the node reports no links. Islands and per-device bandwidth then decide,
+// so a device without a reported bandwidth never passes for NVLink speed.
+func linkGraph(free []apiv1.Device, links []apiv1.Link) *topo.Graph {
+	if len(links) == 0 {
+		return nil
+	}
+	ids := make([]int, len(free))
+	for i, d := range free {
+		ids[i] = d.ID
+	}
+	g := topo.NewGraph(ids)
+	for _, l := range links {
+		g.Connect(l.A, l.B, topo.Link{Type: topo.LinkType(l.Type), Bandwidth: l.Bandwidth})
+	}
+	return g
+}
+
+// pickDevices runs the policy engine over a node's free devices for the claim.
+// The pick's bandwidth is its slowest pairwise link when the node reports
+// links, and the lowest device bandwidth otherwise.
+func pickDevices(free []apiv1.Device, links []apiv1.Link, data *stateData) (int64, nodePick, bool) {
+	devs := deviceInfos(free)
+	req := topoRequest(data.claim.Spec, data.reqCount)
+	req.Graph = linkGraph(free, links)
+	score, ids, ok := topo.Pick(devs, req)
+	if !ok {
+		return 0, nodePick{}, false
+	}
+	bandwidth := topo.MinBandwidth(devs, ids)
+	if req.Graph != nil && len(ids) > 1 {
+		bandwidth = req.Graph.MinBandwidth(ids)
+	}
+	return int64(score), nodePick{ids: ids, bandwidth: bandwidth}, true
+}
+
+// unsatisfiedMessage explains why enough free GPUs still yield no pick.
+func unsatisfiedMessage(data *stateData, free int, excluded []string) string {
+	spec := data.claim.Spec
+	if spec.Topology != nil && spec.Topology.Mode == topo.TopologyRequired {
+		return fmt.Sprintf("no %d free GPUs joined by NVLink at >= %d GB/s (free=%d)%s",
+			data.reqCount, spec.Topology.MinBandwidthGBps, free, excludedSuffix(excluded))
 	}
-	return gns, nil
+	return fmt.Sprintf("free GPUs do not satisfy policy %q (requested=%d, free=%d, preferIds=%v)%s",
+		spec.Devices.Policy, data.reqCount, free, spec.Devices.PreferIDs, excludedSuffix(excluded))
 }
 
 func readState(cycleState *framework.CycleState) (*stateData, error) {
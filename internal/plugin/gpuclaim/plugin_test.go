@@ -0,0 +1,500 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/alloc"
+	"github.com/ziwon/gpu-scheduler/internal/lease"
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+	configv1 "github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config/v1"
+	"github.com/ziwon/gpu-scheduler/internal/topo"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+func newTestPlugin(t *testing.T, objs ...runtime.Object) *Plugin {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiv1.AddToScheme(scheme))
+
+	var crObjs, csObjs []runtime.Object
+	for _, o := range objs {
+		if _, ok := o.(*corev1.Pod); ok {
+			csObjs = append(csObjs, o)
+		} else {
+			crObjs = append(crObjs, o)
+		}
+	}
+
+	// Seed the GPU cache as the informers would on startup.
+	gpus := newGPUCache()
+	for _, o := range crObjs {
+		gpus.update(o)
+	}
+
+	return &Plugin{
+		client: fake.NewSimpleClientset(csObjs...),
+		crcClient: crfake.NewClientBuilder().
+			WithScheme(scheme).
+			WithRuntimeObjects(crObjs...).
+			WithStatusSubresource(&apiv1.GpuClaim{}).
+			Build(),
+		gpus:        gpus,
+		waiting:     fakeWaitingPods{},
+		gangTimeout: defaultGangTimeout,
+		args:        defaultArgs(t),
+	}
+}
+
+// defaultArgs returns the plugin args the scheduler passes without pluginConfig.
+func defaultArgs(t *testing.T) config.GpuClaimPluginArgs {
+	t.Helper()
+	v1args := &configv1.GpuClaimPluginArgs{}
+	configv1.SetDefaults_GpuClaimPluginArgs(v1args)
+	var args config.GpuClaimPluginArgs
+	if err := configv1.Convert_v1_GpuClaimPluginArgs_To_config_GpuClaimPluginArgs(v1args, &args, nil); err != nil {
+		t.Fatal(err)
+	}
+	return args
+}
+
+func gpuLease(ns, node string, id int) *coordv1.Lease {
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      lease.LeaseName(node, id),
+			Namespace: ns,
+			Labels:    map[string]string{"gpu.scheduling/managed": "true"},
+		},
+	}
+}
+
+func nodeStatus(node string, devs ...apiv1.Device) *apiv1.GpuNodeStatus {
+	return &apiv1.GpuNodeStatus{
+		ObjectMeta: metav1.ObjectMeta{Name: node},
+		Spec:       apiv1.GpuNodeStatusSpec{NodeName: node},
+		Status:     apiv1.GpuNodeStatusStatus{Devices: devs, Total: len(devs)},
+	}
+}
+
+func claimPod(claim string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "trainer",
+			Namespace:   "default",
+			UID:         "uid-trainer",
+			Annotations: map[string]string{util.AnnoClaim: claim},
+		},
+	}
+}
+
+func nodeInfo(name string) *framework.NodeInfo {
+	ni := framework.NewNodeInfo()
+	ni.SetNode(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	return ni
+}
+
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 2}},
+	}
+
+	p := newTestPlugin(t,
+		claim,
+		nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}, apiv1.Device{ID: 2}),
+		nodeStatus("node-b", apiv1.Device{ID: 0}, apiv1.Device{ID: 1, Health: "Unhealthy"}),
+		nodeStatus("node-c", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}),
+		// A legacy lease not yet migrated by the GC still makes the GPU unavailable.
+		gpuLease("other", "node-c", 1),
+	)
+
+	pod := claimPod("two")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+
+	tests := []struct {
+		node string
+		code framework.Code
+	}{
+		{node: "node-a", code: framework.Success},
+		{node: "node-b", code: framework.Unschedulable},
+		{node: "node-c", code: framework.Unschedulable},
+		{node: "node-d", code: framework.UnschedulableAndUnresolvable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.node, func(t *testing.T) {
+			st := p.Filter(ctx, state, pod, nodeInfo(tt.node))
+			if st.Code() != tt.code {
+				t.Errorf("Filter(%s) = %v, want %v", tt.node, st, tt.code)
+			}
+		})
+	}
+}
+
+func TestScorePrefersContiguousIsland(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "default"},
+		Spec: apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{
+			Count:  2,
+			Policy: topo.PolicyContiguous,
+		}},
+	}
+	p := newTestPlugin(t,
+		claim,
+		// Contiguous pair in one NVLink island.
+		nodeStatus("node-a",
+			apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+			apiv1.Device{ID: 1, Island: "nv0", Bandwidth: 600}),
+		// Free GPUs split across islands.
+		nodeStatus("node-b",
+			apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+			apiv1.Device{ID: 1, Island: "nv1", Bandwidth: 600}),
+	)
+
+	pod := claimPod("two")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+
+	var scores framework.NodeScoreList
+	for _, name := range []string{"node-a", "node-b"} {
+		s, st := p.Score(ctx, state, pod, nodeInfo(name))
+		if !st.IsSuccess() {
+			t.Fatalf("Score(%s): %v", name, st)
+		}
+		scores = append(scores, framework.NodeScore{Name: name, Score: s})
+	}
+	if st := p.NormalizeScore(ctx, state, pod, scores); !st.IsSuccess() {
+		t.Fatalf("NormalizeScore: %v", st)
+	}
+	if scores[0].Score != framework.MaxNodeScore || scores[1].Score >= scores[0].Score {
+		t.Errorf("normalized scores = %v, want node-a=%d above node-b", scores, framework.MaxNodeScore)
+	}
+}
+
+func TestReserveAcquiresScoredPick(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 2}},
+	}
+	p := newTestPlugin(t,
+		claim,
+		// GPU 0 sits alone in its island; 1 and 2 form the contiguous pick.
+		nodeStatus("node-a",
+			apiv1.Device{ID: 0, Island: "nv0"},
+			apiv1.Device{ID: 1, Island: "nv1"},
+			apiv1.Device{ID: 2, Island: "nv1"},
+			apiv1.Device{ID: 3, Island: "nv2"}),
+	)
+
+	pod := claimPod("two")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	if _, st := p.Score(ctx, state, pod, nodeInfo("node-a")); !st.IsSuccess() {
+		t.Fatalf("Score: %v", st)
+	}
+	if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("Reserve: %v", st)
+	}
+
+	data, _ := readState(state)
+	if got := fmt.Sprint(data.chosenIDs); got != "[1 2]" {
+		t.Errorf("chosenIDs = %s, want [1 2]", got)
+	}
+	na := &apiv1.GpuNodeAllocation{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Name: "node-a"}, na); err != nil {
+		t.Fatalf("get GpuNodeAllocation: %v", err)
+	}
+	if got := na.Spec.Allocations; len(got) != 1 || got[0].UID != "uid-trainer" || fmt.Sprint(got[0].GPUIds) != "[1 2]" {
+		t.Errorf("allocations = %+v, want uid-trainer holding [1 2]", got)
+	}
+
+	p.Unreserve(ctx, state, pod, "node-a")
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Name: "node-a"}, na); err != nil {
+		t.Fatal(err)
+	}
+	if len(na.Spec.Allocations) != 0 {
+		t.Errorf("allocations after Unreserve = %+v, want none", na.Spec.Allocations)
+	}
+}
+
+func TestReserveRepicksAfterAllocationRace(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}},
+	}
+	p := newTestPlugin(t,
+		claim,
+		nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}),
+	)
+
+	pod := claimPod("one")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	if st := p.Filter(ctx, state, pod, nodeInfo("node-a")); !st.IsSuccess() {
+		t.Fatalf("Filter: %v", st)
+	}
+
+	// Another pod grabs the picked GPU between Filter and Reserve.
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "uid-other"}}
+	if err := alloc.Acquire(ctx, p.crcClient, "node-a", other, []int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("Reserve: %v", st)
+	}
+	data, _ := readState(state)
+	if got := fmt.Sprint(data.chosenIDs); got != "[1]" {
+		t.Errorf("chosenIDs = %s, want [1]", got)
+	}
+}
+
+func TestRequiredTopologyRecordsBandwidth(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast", Namespace: "default"},
+		Spec: apiv1.GpuClaimSpec{
+			Devices:  apiv1.DeviceRequest{Count: 2},
+			Topology: &apiv1.TopologyPolicy{Mode: topo.TopologyRequired, MinBandwidthGBps: 300},
+		},
+	}
+	p := newTestPlugin(t,
+		claim,
+		nodeStatus("node-a",
+			apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+			apiv1.Device{ID: 1, Island: "nv0", Bandwidth: 600}),
+		nodeStatus("node-b",
+			apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+			apiv1.Device{ID: 1, Island: "nv1", Bandwidth: 600}),
+		nodeStatus("node-c",
+			apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+			apiv1.Device{ID: 1, Island: "nv0", Bandwidth: 200}),
+		// A legacy agent: one island, no bandwidth reported.
+		nodeStatus("node-d",
+			apiv1.Device{ID: 0, Island: "default"},
+			apiv1.Device{ID: 1, Island: "default"}),
+		claimPod("fast"),
+	)
+
+	pod := claimPod("fast")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	// Without links, islands and device bandwidth decide; an unreported
+	// bandwidth does not count as NVLink speed.
+	for _, node := range []string{"node-b", "node-c", "node-d"} {
+		if st := p.Filter(ctx, state, pod, nodeInfo(node)); st.Code() != framework.Unschedulable {
+			t.Errorf("Filter(%s) = %v, want Unschedulable", node, st)
+		}
+	}
+	if st := p.Filter(ctx, state, pod, nodeInfo("node-a")); !st.IsSuccess() {
+		t.Fatalf("Filter(node-a): %v", st)
+	}
+	if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("Reserve: %v", st)
+	}
+	if st := p.PreBind(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("PreBind: %v", st)
+	}
+
+	got := &apiv1.GpuClaim{}
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "fast"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "allocated GPUs [0 1] on node-a (bandwidth 600 GB/s)"; got.Status.Message != want {
+		t.Errorf("claim message = %q, want %q", got.Status.Message, want)
+	}
+}
+
+func TestRequiredTopologyUsesLinks(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "fast", Namespace: "default"},
+		Spec: apiv1.GpuClaimSpec{
+			Devices:  apiv1.DeviceRequest{Count: 2},
+			Topology: &apiv1.TopologyPolicy{Mode: topo.TopologyRequired, MinBandwidthGBps: 300},
+		},
+	}
+	// One island by device data, but GPUs 0 and 1 only share a PCIe switch.
+	gns := nodeStatus("node-a",
+		apiv1.Device{ID: 0, Island: "nv0", Bandwidth: 600},
+		apiv1.Device{ID: 1, Island: "nv0", Bandwidth: 600},
+		apiv1.Device{ID: 2, Island: "nv0", Bandwidth: 600},
+		apiv1.Device{ID: 3, Island: "nv0", Bandwidth: 600})
+	gns.Status.Links = []apiv1.Link{
+		{A: 0, B: 1, Type: string(topo.LinkPCIeSwitch)},
+		{A: 1, B: 2, Type: string(topo.LinkNVLink), Bandwidth: 100},
+		{A: 2, B: 3, Type: string(topo.LinkNVLink), Bandwidth: 450},
+	}
+	p := newTestPlugin(t, claim, gns, claimPod("fast"))
+
+	pod := claimPod("fast")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	if st := p.Filter(ctx, state, pod, nodeInfo("node-a")); !st.IsSuccess() {
+		t.Fatalf("Filter: %v", st)
+	}
+	if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+		t.Fatalf("Reserve: %v", st)
+	}
+	data, _ := readState(state)
+	if got := fmt.Sprint(data.chosenIDs); got != "[2 3]" {
+		t.Errorf("chosenIDs = %s, want [2 3]", got)
+	}
+	if data.chosenBandwidth != 450 {
+		t.Errorf("chosenBandwidth = %d, want the 450 GB/s link", data.chosenBandwidth)
+	}
+}
+
+func TestFilterSelector(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "h100", Namespace: "default"},
+		Spec: apiv1.GpuClaimSpec{
+			Devices: apiv1.DeviceRequest{Count: 1},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"pool": "training"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "gpu.product",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{"H100", "H200"},
+				}},
+			},
+		},
+	}
+	p := newTestPlugin(t,
+		claim,
+		nodeStatus("node-a", apiv1.Device{ID: 0}),
+		nodeStatus("node-b", apiv1.Device{ID: 0}),
+	)
+
+	pod := claimPod("h100")
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+
+	tests := []struct {
+		node   string
+		labels map[string]string
+		code   framework.Code
+	}{
+		{node: "node-a", labels: map[string]string{"pool": "training", "gpu.product": "H100"}, code: framework.Success},
+		{node: "node-b", labels: map[string]string{"pool": "training", "gpu.product": "A100"}, code: framework.UnschedulableAndUnresolvable},
+	}
+	for _, tt := range tests {
+		ni := framework.NewNodeInfo()
+		ni.SetNode(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: tt.node, Labels: tt.labels}})
+		if st := p.Filter(ctx, state, pod, ni); st.Code() != tt.code {
+			t.Errorf("Filter(%s) = %v, want %v", tt.node, st, tt.code)
+		}
+	}
+}
+
+func TestFilterHonorsArgs(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{ObjectMeta: metav1.ObjectMeta{Name: "unset", Namespace: "default"}}
+	p := newTestPlugin(t, claim, nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}, apiv1.Device{ID: 2}))
+	p.args.DefaultGPUCount = 3
+	p.args.MaxGPUID = 1
+
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, claimPod("unset")); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	st := p.Filter(ctx, state, claimPod("unset"), nodeInfo("node-a"))
+	if want := "requested=3, free=2"; st.Code() != framework.Unschedulable || !strings.Contains(st.Message(), want) {
+		t.Errorf("Filter = %v, want Unschedulable with %q", st, want)
+	}
+}
+
+func TestFilterHealthAndOccupancy(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 2}},
+	}
+	status := nodeStatus("node-a",
+		apiv1.Device{ID: 0, Health: apiv1.HealthHealthy},
+		apiv1.Device{ID: 1, Health: apiv1.HealthUnknown},
+		apiv1.Device{ID: 2, Health: apiv1.HealthUnhealthy},
+		// Running outside the scheduler's ledger, as reported by the agent.
+		apiv1.Device{ID: 3, Health: apiv1.HealthHealthy, InUseBy: []string{"uid-other"}},
+	)
+
+	tests := []struct {
+		name     string
+		unknown  config.UnknownHealthPolicy
+		code     framework.Code
+		excluded string
+	}{
+		{name: "unknown allowed", unknown: config.AllowUnknown, code: framework.Success},
+		{
+			name:     "unknown excluded",
+			unknown:  config.ExcludeUnknown,
+			code:     framework.Unschedulable,
+			excluded: "excluded GPUs: 1 (health Unknown), 2 (health Unhealthy), 3 (in use by uid-other)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlugin(t, claim, status)
+			p.args.UnknownHealth = tt.unknown
+
+			pod := claimPod("two")
+			state := framework.NewCycleState()
+			if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+				t.Fatalf("PreFilter: %v", st)
+			}
+			st := p.Filter(ctx, state, pod, nodeInfo("node-a"))
+			if st.Code() != tt.code || !strings.Contains(st.Message(), tt.excluded) {
+				t.Fatalf("Filter = %v, want %v with %q", st, tt.code, tt.excluded)
+			}
+			if tt.code != framework.Success {
+				return
+			}
+			if st := p.Reserve(ctx, state, pod, "node-a"); !st.IsSuccess() {
+				t.Fatalf("Reserve: %v", st)
+			}
+			got, err := alloc.Held(ctx, p.crcClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []int{2, 3} {
+				if got.Has("node-a", id) {
+					t.Errorf("Reserve allocated excluded GPU %d", id)
+				}
+			}
+		})
+	}
+}
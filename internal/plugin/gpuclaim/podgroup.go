@@ -0,0 +1,195 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/util"
+)
+
+// podGroupKind is an external queueing object a gangRef may point at, written
+// as "<Kind>/<name>". Objects are read unstructured, so neither Volcano nor
+// Kueue is a build dependency.
+type podGroupKind struct {
+	gvk  schema.GroupVersionKind
+	read func(*unstructured.Unstructured) (podGroupState, error)
+}
+
+// podGroupState is what the plugin needs from an external pod group.
+type podGroupState struct {
+	minMember int
+	admitted  bool
+	phase     string
+}
+
+var podGroupKinds = map[string]podGroupKind{
+	"PodGroup": {
+		gvk:  schema.GroupVersionKind{Group: "scheduling.volcano.sh", Version: "v1beta1", Kind: "PodGroup"},
+		read: readVolcanoPodGroup,
+	},
+	"Workload": {
+		gvk:  schema.GroupVersionKind{Group: "kueue.x-k8s.io", Version: "v1beta1", Kind: "Workload"},
+		read: readKueueWorkload,
+	},
+}
+
+// splitGangRef returns the external kind and name of a gangRef, or ok=false
+// for a plain gang name.
+func splitGangRef(ref string) (kind podGroupKind, name string, ok bool) {
+	k, name, found := strings.Cut(ref, "/")
+	if !found {
+		return podGroupKind{}, "", false
+	}
+	kind, ok = podGroupKinds[k]
+	return kind, name, ok
+}
+
+// resolvePodGroup sizes a gang from its external pod group and refuses to
+// reserve GPUs until the queue has admitted the group.
+func (p *Plugin) resolvePodGroup(ctx context.Context, g *gang) *framework.Status {
+	kind, name, ok := splitGangRef(g.name)
+	if !ok {
+		return nil
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(kind.gvk)
+	if err := p.crcClient.Get(ctx, types.NamespacedName{Namespace: g.namespace, Name: name}, u); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			msg := fmt.Sprintf("gangRef %q: %s is not installed in the cluster", g.name, kind.gvk.GroupKind())
+			return framework.NewStatus(framework.UnschedulableAndUnresolvable, msg)
+		case apierrors.IsNotFound(err):
+			return framework.NewStatus(framework.Unschedulable, fmt.Sprintf("gangRef %q not found", g.name))
+		default:
+			return framework.AsStatus(fmt.Errorf("get gangRef %q: %w", g.name, err))
+		}
+	}
+
+	state, err := kind.read(u)
+	if err != nil {
+		return framework.NewStatus(framework.UnschedulableAndUnresolvable, fmt.Sprintf("gangRef %q: %v", g.name, err))
+	}
+	if !state.admitted {
+		msg := fmt.Sprintf("gangRef %q not admitted by its queue yet (phase=%s)", g.name, state.phase)
+		return framework.NewStatus(framework.Unschedulable, msg)
+	}
+	g.size = state.minMember
+	return nil
+}
+
+// podGroupEvents requeues gang pods when their external pod group is admitted.
+// Kinds whose CRD is not installed are left out, so the scheduler does not
+// start dynamic informers for resources that do not exist.
+func (p *Plugin) podGroupEvents(ctx context.Context) []framework.ClusterEventWithHint {
+	var events []framework.ClusterEventWithHint
+	for _, name := range slices.Sorted(maps.Keys(podGroupKinds)) {
+		gvk := podGroupKinds[name].gvk
+		m, err := p.crcClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if !meta.IsNoMatchError(err) {
+				klog.FromContext(ctx).Error(err, "failed to look up pod group kind, not watching it", "kind", gvk.String())
+			}
+			continue
+		}
+		resource := framework.EventResource(fmt.Sprintf("%s.%s.%s", m.Resource.Resource, m.Resource.Version, m.Resource.Group))
+		events = append(events, framework.ClusterEventWithHint{
+			Event:          framework.ClusterEvent{Resource: resource, ActionType: framework.Update},
+			QueueingHintFn: p.isPodGroupAdmitted(name),
+		})
+	}
+	return events
+}
+
+// isPodGroupAdmitted queues a pod when the pod group its claim's gangRef
+// points at becomes admitted.
+func (p *Plugin) isPodGroupAdmitted(kindName string) framework.QueueingHintFn {
+	kind := podGroupKinds[kindName]
+	return func(logger klog.Logger, pod *corev1.Pod, oldObj, newObj interface{}) (framework.QueueingHint, error) {
+		oldU, newU, err := convertPair[unstructured.Unstructured](oldObj, newObj)
+		if err != nil {
+			return framework.Queue, err
+		}
+		if newU == nil || newU.GetNamespace() != pod.Namespace {
+			return framework.QueueSkip, nil
+		}
+		if state, err := kind.read(newU); err != nil || !state.admitted {
+			return framework.QueueSkip, nil
+		}
+		if oldU != nil {
+			if state, err := kind.read(oldU); err == nil && state.admitted {
+				return framework.QueueSkip, nil
+			}
+		}
+
+		claim := &apiv1.GpuClaim{}
+		key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Annotations[util.AnnoClaim]}
+		if err := p.crcClient.Get(context.Background(), key, claim); err != nil {
+			return framework.Queue, err
+		}
+		if claim.Spec.GangRef != kindName+"/"+newU.GetName() {
+			return framework.QueueSkip, nil
+		}
+		logger.V(5).Info("pod group admitted", "pod", klog.KObj(pod), "gangRef", claim.Spec.GangRef)
+		return framework.Queue, nil
+	}
+}
+
+// readVolcanoPodGroup reads spec.minMember; Volcano moves a PodGroup to
+// Inqueue once its queue admits it.
+func readVolcanoPodGroup(u *unstructured.Unstructured) (podGroupState, error) {
+	minMember, _, err := unstructured.NestedInt64(u.Object, "spec", "minMember")
+	if err != nil {
+		return podGroupState{}, fmt.Errorf("read spec.minMember: %w", err)
+	}
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	return podGroupState{
+		minMember: int(minMember),
+		admitted:  phase == "Inqueue" || phase == "Running",
+		phase:     phase,
+	}, nil
+}
+
+// readKueueWorkload sums the pods of every pod set, honoring minCount for
+// partial admission, and checks the Admitted condition.
+func readKueueWorkload(u *unstructured.Unstructured) (podGroupState, error) {
+	podSets, _, err := unstructured.NestedSlice(u.Object, "spec", "podSets")
+	if err != nil {
+		return podGroupState{}, fmt.Errorf("read spec.podSets: %w", err)
+	}
+	var state podGroupState
+	for _, raw := range podSets {
+		ps, ok := raw.(map[string]interface{})
+		if !ok {
+			return podGroupState{}, fmt.Errorf("malformed spec.podSets entry")
+		}
+		count, _, _ := unstructured.NestedInt64(ps, "count")
+		if minCount, found, _ := unstructured.NestedInt64(ps, "minCount"); found {
+			count = minCount
+		}
+		state.minMember += int(count)
+	}
+
+	state.phase = "Pending"
+	conds, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, raw := range conds {
+		c, ok := raw.(map[string]interface{})
+		if ok && c["type"] == "Admitted" && c["status"] == "True" {
+			state.admitted, state.phase = true, "Admitted"
+		}
+	}
+	return state, nil
+}
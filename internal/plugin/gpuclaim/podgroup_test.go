@@ -0,0 +1,219 @@
+package gpuclaim
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+func volcanoPodGroup(name string, minMember int64, phase string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec":   map[string]interface{}{"minMember": minMember},
+		"status": map[string]interface{}{"phase": phase},
+	}}
+	u.SetGroupVersionKind(podGroupKinds["PodGroup"].gvk)
+	u.SetNamespace("default")
+	u.SetName(name)
+	return u
+}
+
+func kueueWorkload(name string, admitted bool, podSets ...map[string]interface{}) *unstructured.Unstructured {
+	sets := make([]interface{}, len(podSets))
+	for i, ps := range podSets {
+		sets[i] = ps
+	}
+	status := "False"
+	if admitted {
+		status = "True"
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"podSets": sets},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Admitted", "status": status},
+		}},
+	}}
+	u.SetGroupVersionKind(podGroupKinds["Workload"].gvk)
+	u.SetNamespace("default")
+	u.SetName(name)
+	return u
+}
+
+func TestPreFilterPodGroup(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		gangRef   string
+		objs      []*unstructured.Unstructured
+		installed bool
+		wantCode  framework.Code
+		wantSize  int
+	}{
+		{
+			name:      "volcano admitted",
+			gangRef:   "PodGroup/train",
+			objs:      []*unstructured.Unstructured{volcanoPodGroup("train", 4, "Inqueue")},
+			installed: true,
+			wantSize:  4,
+		},
+		{
+			name:      "volcano pending",
+			gangRef:   "PodGroup/train",
+			objs:      []*unstructured.Unstructured{volcanoPodGroup("train", 4, "Pending")},
+			installed: true,
+			wantCode:  framework.Unschedulable,
+		},
+		{
+			name:    "kueue admitted with partial admission",
+			gangRef: "Workload/job-train-1a2b3",
+			objs: []*unstructured.Unstructured{kueueWorkload("job-train-1a2b3", true,
+				map[string]interface{}{"name": "launcher", "count": int64(1)},
+				map[string]interface{}{"name": "worker", "count": int64(8), "minCount": int64(4)},
+			)},
+			installed: true,
+			wantSize:  5,
+		},
+		{
+			name:      "kueue not admitted",
+			gangRef:   "Workload/job-train-1a2b3",
+			objs:      []*unstructured.Unstructured{kueueWorkload("job-train-1a2b3", false, map[string]interface{}{"count": int64(2)})},
+			installed: true,
+			wantCode:  framework.Unschedulable,
+		},
+		{
+			name:      "missing object",
+			gangRef:   "PodGroup/train",
+			installed: true,
+			wantCode:  framework.Unschedulable,
+		},
+		{
+			name:     "CRD not installed",
+			gangRef:  "PodGroup/train",
+			wantCode: framework.UnschedulableAndUnresolvable,
+		},
+		{
+			name:     "plain gang name",
+			gangRef:  "train",
+			wantSize: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &apiv1.GpuClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+				Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}, GangRef: tt.gangRef, GangSize: 2},
+			}
+			p := newTestPlugin(t, claim)
+
+			scheme := runtime.NewScheme()
+			if err := apiv1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if tt.installed {
+				for _, kind := range podGroupKinds {
+					scheme.AddKnownTypeWithName(kind.gvk, &unstructured.Unstructured{})
+				}
+			}
+			builder := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(claim)
+			if !tt.installed {
+				// The fake client has no discovery; fail as a real RESTMapper would.
+				builder = builder.WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, c crclient.WithWatch, key crclient.ObjectKey, obj crclient.Object, opts ...crclient.GetOption) error {
+						if u, ok := obj.(*unstructured.Unstructured); ok {
+							gvk := u.GroupVersionKind()
+							return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+						}
+						return c.Get(ctx, key, obj, opts...)
+					},
+				})
+			}
+			for _, o := range tt.objs {
+				builder = builder.WithObjects(o)
+			}
+			p.crcClient = builder.Build()
+
+			state := framework.NewCycleState()
+			_, st := p.PreFilter(ctx, state, claimPod("worker"))
+			if st.Code() != tt.wantCode {
+				t.Fatalf("PreFilter = %v, want %v", st, tt.wantCode)
+			}
+			if tt.wantCode != framework.Success {
+				return
+			}
+			data, err := readState(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.gang.size != tt.wantSize {
+				t.Errorf("gang size = %d, want %d", data.gang.size, tt.wantSize)
+			}
+		})
+	}
+}
+
+// podGroupMapper maps the external pod group kinds as if their CRDs were installed.
+func podGroupMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, kind := range podGroupKinds {
+		mapper.Add(kind.gvk, meta.RESTScopeNamespace)
+	}
+	return mapper
+}
+
+func TestPodGroupAdmittedHint(t *testing.T) {
+	claim := func(gangRef string) *apiv1.GpuClaim {
+		return &apiv1.GpuClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+			Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}, GangRef: gangRef},
+		}
+	}
+	elsewhere := volcanoPodGroup("train", 2, "Inqueue")
+	elsewhere.SetNamespace("other")
+
+	tests := []struct {
+		name   string
+		kind   string
+		claim  *apiv1.GpuClaim
+		oldObj *unstructured.Unstructured
+		newObj *unstructured.Unstructured
+		want   framework.QueueingHint
+	}{
+		{"volcano group admitted", "PodGroup", claim("PodGroup/train"),
+			volcanoPodGroup("train", 2, "Pending"), volcanoPodGroup("train", 2, "Inqueue"), framework.Queue},
+		{"volcano group already admitted", "PodGroup", claim("PodGroup/train"),
+			volcanoPodGroup("train", 2, "Inqueue"), volcanoPodGroup("train", 2, "Running"), framework.QueueSkip},
+		{"volcano group still pending", "PodGroup", claim("PodGroup/train"),
+			volcanoPodGroup("train", 2, "Pending"), volcanoPodGroup("train", 3, "Pending"), framework.QueueSkip},
+		{"other group admitted", "PodGroup", claim("PodGroup/eval"),
+			volcanoPodGroup("train", 2, "Pending"), volcanoPodGroup("train", 2, "Inqueue"), framework.QueueSkip},
+		{"group in other namespace", "PodGroup", claim("PodGroup/train"),
+			volcanoPodGroup("train", 2, "Pending"), elsewhere, framework.QueueSkip},
+		{"kueue workload admitted", "Workload", claim("Workload/train"),
+			kueueWorkload("train", false), kueueWorkload("train", true), framework.Queue},
+		{"kueue workload of plain gang", "Workload", claim("train"),
+			kueueWorkload("train", false), kueueWorkload("train", true), framework.QueueSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlugin(t, tt.claim)
+			hint := p.isPodGroupAdmitted(tt.kind)
+			got, err := hint(klog.Background(), claimPod("worker"), tt.oldObj, tt.newObj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("hint = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package gpuclaim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/klog/v2"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"github.com/ziwon/gpu-scheduler/internal/alloc"
+)
+
+// preemptionHandle is the part of framework.Handle used for preemption.
+type preemptionHandle interface {
+	SnapshotSharedLister() framework.SharedLister
+	RejectWaitingPod(uid types.UID) bool
+	RunPreFilterExtensionRemovePod(ctx context.Context, state *framework.CycleState, podToSchedule *corev1.Pod, podInfoToRemove *framework.PodInfo, nodeInfo *framework.NodeInfo) *framework.Status
+	RunFilterPluginsWithNominatedPods(ctx context.Context, state *framework.CycleState, pod *corev1.Pod, info *framework.NodeInfo) *framework.Status
+}
+
+// candidate is a node where evicting victims frees a usable device set.
+type candidate struct {
+	node    string
+	victims []*corev1.Pod
+}
+
+// PostFilter preempts lower-priority GPU holders when the pod was rejected
+// only for lack of GPUs. It picks the node needing the fewest, lowest-priority
+// victims on which every filter plugin passes once they are gone, evicts them
+// and nominates the node; the GC releases their GPUs once the pods are gone.
+// If any eviction fails the node is not nominated.
+func (p *Plugin) PostFilter(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, statuses framework.NodeToStatusReader) (*framework.PostFilterResult, *framework.Status) {
+	data, err := readState(cycleState)
+	if err != nil {
+		return nil, framework.NewStatus(framework.Unschedulable, err.Error())
+	}
+	if pod.Spec.PreemptionPolicy != nil && *pod.Spec.PreemptionPolicy == corev1.PreemptNever {
+		return nil, framework.NewStatus(framework.Unschedulable, "pod does not preempt")
+	}
+
+	nodes, err := statuses.NodesForStatusCode(p.preempt.SnapshotSharedLister().NodeInfos(), framework.Unschedulable)
+	if err != nil {
+		return nil, framework.AsStatus(err)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node().Name < nodes[j].Node().Name })
+
+	var candidates []*candidate
+	for _, ni := range nodes {
+		if statuses.Get(ni.Node().Name).Plugin() != Name {
+			continue
+		}
+		if c, ok := p.selectVictims(data, pod, ni); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return betterCandidate(candidates[i], candidates[j]) })
+
+	var best *candidate
+	for _, c := range candidates {
+		st := p.fitsWithoutVictims(ctx, cycleState, pod, c)
+		if st.IsSuccess() {
+			best = c
+			break
+		}
+		klog.V(4).InfoS("preemption candidate rejected by filters", "pod", klog.KObj(pod), "node", c.node, "status", st)
+	}
+	if best == nil {
+		return nil, framework.NewStatus(framework.Unschedulable, "preemption of lower-priority pods frees no usable GPUs")
+	}
+
+	var errs []error
+	for _, victim := range best.victims {
+		if err := p.evict(ctx, victim); err != nil {
+			errs = append(errs, fmt.Errorf("preempt %s: %w", klog.KObj(victim), err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, framework.NewStatus(framework.Unschedulable, err.Error())
+	}
+	klog.V(2).InfoS("preempted pods for GPUs", "pod", klog.KObj(pod), "node", best.node, "victims", klog.KObjSlice(best.victims))
+	return framework.NewPostFilterResultWithNominatedNode(best.node), framework.NewStatus(framework.Success)
+}
+
+// fitsWithoutVictims runs every filter plugin for the pod on the candidate
+// node with its victims removed, on copies of the node and cycle state, so a
+// node that stays unschedulable for reasons other than GPUs is not chosen.
+func (p *Plugin) fitsWithoutVictims(ctx context.Context, cycleState *framework.CycleState, pod *corev1.Pod, c *candidate) *framework.Status {
+	ni, err := p.preempt.SnapshotSharedLister().NodeInfos().Get(c.node)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
+	ni = ni.Snapshot()
+	state := cycleState.Clone()
+	for _, victim := range c.victims {
+		pi, err := framework.NewPodInfo(victim)
+		if err != nil {
+			return framework.AsStatus(err)
+		}
+		if err := ni.RemovePod(klog.FromContext(ctx), victim); err != nil {
+			return framework.AsStatus(err)
+		}
+		if st := p.preempt.RunPreFilterExtensionRemovePod(ctx, state, pod, pi, ni); !st.IsSuccess() {
+			return st
+		}
+	}
+	return p.preempt.RunFilterPluginsWithNominatedPods(ctx, state, pod, ni)
+}
+
+// selectVictims returns the smallest set of lower-priority pods on the node
+// whose GPUs, once freed, give the claim a policy-compliant device set.
+// Like the default preemption it removes every lower-priority holder, then
+// reprieves them highest priority first while the pick still succeeds.
+func (p *Plugin) selectVictims(data *stateData, pod *corev1.Pod, ni *framework.NodeInfo) (*candidate, bool) {
+	node := ni.Node().Name
+	gns, ok := p.gpus.nodeStatus(node)
+	if !ok {
+		return nil, false
+	}
+
+	holders := map[types.UID]bool{}
+	for _, uid := range data.held[node] {
+		holders[uid] = true
+	}
+	prio := corev1helpers.PodPriority(pod)
+	var lower []*corev1.Pod
+	for _, pi := range ni.Pods {
+		if holders[pi.Pod.UID] && corev1helpers.PodPriority(pi.Pod) < prio {
+			lower = append(lower, pi.Pod)
+		}
+	}
+
+	fits := func(evicted map[types.UID]bool) bool {
+		held := alloc.Set{}
+		for id, uid := range data.held[node] {
+			if !evicted[uid] {
+				held.Add(node, id, uid)
+			}
+		}
+		free, _, _ := p.availableDevices(gns, held, evicted)
+		if len(free) < data.reqCount {
+			return false
+		}
+		_, _, ok := pickDevices(free, gns.Status.Links, data)
+		return ok
+	}
+
+	evicted := map[types.UID]bool{}
+	for _, v := range lower {
+		evicted[v.UID] = true
+	}
+	if len(lower) == 0 || !fits(evicted) {
+		return nil, false
+	}
+
+	sort.SliceStable(lower, func(i, j int) bool {
+		return corev1helpers.PodPriority(lower[i]) > corev1helpers.PodPriority(lower[j])
+	})
+	c := &candidate{node: node}
+	for _, v := range lower {
+		delete(evicted, v.UID)
+		if !fits(evicted) {
+			evicted[v.UID] = true
+			c.victims = append(c.victims, v)
+		}
+	}
+	return c, true
+}
+
+// betterCandidate prefers fewer victims, then a lower highest victim priority.
+func betterCandidate(a, b *candidate) bool {
+	if len(a.victims) != len(b.victims) {
+		return len(a.victims) < len(b.victims)
+	}
+	return maxPriority(a.victims) < maxPriority(b.victims)
+}
+
+func maxPriority(pods []*corev1.Pod) int32 {
+	var out int32
+	for i, pod := range pods {
+		if prio := corev1helpers.PodPriority(pod); i == 0 || prio > out {
+			out = prio
+		}
+	}
+	return out
+}
+
+// evict rejects a victim still waiting in Permit, which releases its GPUs
+// right away, and evicts any other victim through the Eviction API.
+func (p *Plugin) evict(ctx context.Context, victim *corev1.Pod) error {
+	if p.preempt.RejectWaitingPod(victim.UID) {
+		return nil
+	}
+	return p.client.PolicyV1().Evictions(victim.Namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: victim.Name, Namespace: victim.Namespace},
+	})
+}
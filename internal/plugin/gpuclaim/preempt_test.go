@@ -0,0 +1,264 @@
+package gpuclaim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	backendcache "k8s.io/kubernetes/pkg/scheduler/backend/cache"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+)
+
+// fakePreemption serves a fixed snapshot and the test's waiting pods. Its
+// filters are the plugin's own plus another rejecting the nodes in reject.
+type fakePreemption struct {
+	snapshot framework.SharedLister
+	waiting  fakeWaitingPods
+	plugin   *Plugin
+	reject   map[string]bool
+}
+
+func (f *fakePreemption) RunPreFilterExtensionRemovePod(ctx context.Context, state *framework.CycleState, pod *corev1.Pod, pi *framework.PodInfo, ni *framework.NodeInfo) *framework.Status {
+	return f.plugin.RemovePod(ctx, state, pod, pi, ni)
+}
+
+func (f *fakePreemption) RunFilterPluginsWithNominatedPods(ctx context.Context, state *framework.CycleState, pod *corev1.Pod, ni *framework.NodeInfo) *framework.Status {
+	if f.reject[ni.Node().Name] {
+		return framework.NewStatus(framework.Unschedulable, "node(s) didn't match Pod's node affinity")
+	}
+	return f.plugin.Filter(ctx, state, pod, ni)
+}
+
+func (f *fakePreemption) SnapshotSharedLister() framework.SharedLister { return f.snapshot }
+
+func (f *fakePreemption) RejectWaitingPod(uid types.UID) bool {
+	w, ok := f.waiting[uid]
+	if ok {
+		w.Reject(Name, "preempted")
+	}
+	return ok
+}
+
+// holder is a pod bound to node holding ids in the node's ledger.
+func holder(name, node string, prio int32, ids ...int) (*corev1.Pod, apiv1.PodAllocation) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+		Spec:       corev1.PodSpec{NodeName: node, Priority: &prio},
+	}
+	return pod, apiv1.PodAllocation{Namespace: "default", Name: name, UID: string(pod.UID), GPUIds: ids}
+}
+
+func TestPostFilterPreemptsLowerPriority(t *testing.T) {
+	ctx := context.Background()
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 2}},
+	}
+	// node-a: evicting low1 alone frees two GPUs. node-b needs two victims.
+	low1, a1 := holder("low1", "node-a", 1, 0, 1)
+	low2, a2 := holder("low2", "node-a", 5, 2)
+	high, a3 := holder("high", "node-a", 100, 3)
+	low3, b1 := holder("low3", "node-b", 0, 0)
+	low4, b2 := holder("low4", "node-b", 0, 1)
+	pods := []*corev1.Pod{low1, low2, high, low3, low4}
+
+	ledger := func(node string, allocs ...apiv1.PodAllocation) *apiv1.GpuNodeAllocation {
+		return &apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: node},
+			Spec:       apiv1.GpuNodeAllocationSpec{NodeName: node, Allocations: allocs},
+		}
+	}
+	four := []apiv1.Device{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}}
+	// The agent reports node-a's holders too; evicting them must still free their GPUs.
+	occupied := []apiv1.Device{
+		{ID: 0, InUseBy: []string{"uid-low1"}},
+		{ID: 1, InUseBy: []string{"uid-low1"}},
+		{ID: 2, InUseBy: []string{"uid-low2"}},
+		{ID: 3, InUseBy: []string{"uid-high"}},
+	}
+	objs := []runtime.Object{
+		claim,
+		nodeStatus("node-a", occupied...),
+		nodeStatus("node-b", four[:2]...),
+		ledger("node-a", a1, a2, a3),
+		ledger("node-b", b1, b2),
+	}
+	nodes := []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}},
+	}
+
+	tests := []struct {
+		name     string
+		prio     int32
+		never    bool
+		waiting  bool
+		reject   string
+		evictErr string
+		wantNode string
+		// wantEvicted are the attempted evictions, successful or not.
+		wantEvicted []string
+	}{
+		{name: "fewest victims", prio: 50, wantNode: "node-a", wantEvicted: []string{"low1"}},
+		{name: "waiting victim is rejected", prio: 50, waiting: true, wantNode: "node-a"},
+		{name: "no lower priority holders", prio: 0},
+		{name: "preemption disabled", prio: 50, never: true},
+		{name: "other filters reject best node", prio: 50, reject: "node-a", wantNode: "node-b", wantEvicted: []string{"low3", "low4"}},
+		{name: "other filters reject every node", prio: 50, reject: "node-a,node-b"},
+		{name: "eviction fails", prio: 50, reject: "node-a", evictErr: "low3", wantEvicted: []string{"low3", "low4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := append([]runtime.Object{}, objs...)
+			for _, pod := range pods {
+				all = append(all, pod)
+			}
+			p := newTestPlugin(t, all...)
+			preempt := &fakePreemption{snapshot: backendcache.NewSnapshot(pods, nodes), waiting: fakeWaitingPods{}, plugin: p, reject: map[string]bool{}}
+			for _, n := range strings.Split(tt.reject, ",") {
+				preempt.reject[n] = true
+			}
+			if tt.evictErr != "" {
+				p.client.(*fake.Clientset).PrependReactor("create", "pods", func(a k8stesting.Action) (bool, runtime.Object, error) {
+					if a.GetSubresource() == "eviction" && a.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction).Name == tt.evictErr {
+						return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+					}
+					return false, nil, nil
+				})
+			}
+			if tt.waiting {
+				preempt.waiting[low1.UID] = &fakeWaitingPod{pod: low1}
+			}
+			p.preempt = preempt
+
+			pod := claimPod("two")
+			pod.Spec.Priority = &tt.prio
+			if tt.never {
+				never := corev1.PreemptNever
+				pod.Spec.PreemptionPolicy = &never
+			}
+			state := framework.NewCycleState()
+			if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+				t.Fatalf("PreFilter: %v", st)
+			}
+			statuses := framework.NewDefaultNodeToStatus()
+			for _, n := range nodes {
+				ni := framework.NewNodeInfo()
+				ni.SetNode(n)
+				st := p.Filter(ctx, state, pod, ni)
+				if st.Code() != framework.Unschedulable {
+					t.Fatalf("Filter(%s) = %v, want Unschedulable", n.Name, st)
+				}
+				statuses.Set(n.Name, st.WithPlugin(Name))
+			}
+
+			res, st := p.PostFilter(ctx, state, pod, statuses)
+
+			var evicted []string
+			for _, a := range p.client.(*fake.Clientset).Actions() {
+				if a.GetSubresource() == "eviction" {
+					evicted = append(evicted, a.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction).Name)
+				}
+			}
+			if fmt.Sprint(evicted) != fmt.Sprint(tt.wantEvicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if tt.wantNode == "" {
+				if st.Code() != framework.Unschedulable || res != nil {
+					t.Fatalf("PostFilter = %v, %v; want Unschedulable without nomination", res, st)
+				}
+				return
+			}
+			if !st.IsSuccess() || res.NominatingInfo.NominatedNodeName != tt.wantNode {
+				t.Fatalf("PostFilter = %v, %v; want nominated %s", res, st, tt.wantNode)
+			}
+			if tt.waiting {
+				if _, rejected := preempt.waiting[low1.UID].verdict(); !rejected {
+					t.Errorf("waiting victim not rejected")
+				}
+			}
+		})
+	}
+}
+
+func TestPreFilterExtensions(t *testing.T) {
+	ctx := context.Background()
+	claim := func(name string, count int) *apiv1.GpuClaim {
+		return &apiv1.GpuClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: count}},
+		}
+	}
+	victim, a := holder("victim", "node-a", 0, 0, 1)
+	nominated := claimPod("one")
+	nominated.Name, nominated.UID = "nominated", "uid-nominated"
+	p := newTestPlugin(t,
+		claim("two", 2), claim("one", 1),
+		nodeStatus("node-a", apiv1.Device{ID: 0}, apiv1.Device{ID: 1}),
+		&apiv1.GpuNodeAllocation{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Spec:       apiv1.GpuNodeAllocationSpec{NodeName: "node-a", Allocations: []apiv1.PodAllocation{a}},
+		},
+	)
+	node := nodeInfo("node-a")
+
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, claimPod("two")); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	filter := func(state *framework.CycleState) framework.Code {
+		return p.Filter(ctx, state, claimPod("two"), node).Code()
+	}
+	if got := filter(state); got != framework.Unschedulable {
+		t.Fatalf("Filter = %v, want Unschedulable", got)
+	}
+
+	// Preemption what-if: removing the victim frees its GPUs on the clone only.
+	sim := state.Clone()
+	if st := p.RemovePod(ctx, sim, nil, mustPodInfo(t, victim), node); !st.IsSuccess() {
+		t.Fatal(st)
+	}
+	if got := filter(sim); got != framework.Success {
+		t.Errorf("Filter after RemovePod = %v, want Success", got)
+	}
+	if got := filter(state); got != framework.Unschedulable {
+		t.Errorf("original state changed by RemovePod: Filter = %v", got)
+	}
+
+	// A nominated pod assumes the GPU its claim would pick.
+	if st := p.AddPod(ctx, sim, nil, mustPodInfo(t, nominated), node); !st.IsSuccess() {
+		t.Fatal(st)
+	}
+	if got := filter(sim); got != framework.Unschedulable {
+		t.Errorf("Filter after AddPod(nominated) = %v, want Unschedulable", got)
+	}
+
+	// Adding the victim back restores its original GPUs.
+	if st := p.AddPod(ctx, sim, nil, mustPodInfo(t, victim), node); !st.IsSuccess() {
+		t.Fatal(st)
+	}
+	data, _ := readState(sim)
+	if data.held["node-a"][0] != victim.UID || data.held["node-a"][1] != victim.UID {
+		t.Errorf("victim GPUs not restored: %v", data.held)
+	}
+}
+
+func mustPodInfo(t *testing.T, pod *corev1.Pod) *framework.PodInfo {
+	t.Helper()
+	pi, err := framework.NewPodInfo(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pi
+}
@@ -0,0 +1,85 @@
+package gpuclaim
+
+import (
+	"slices"
+
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/helper"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+	"github.com/ziwon/gpu-scheduler/internal/topo"
+)
+
+// maxShapeScore is the highest score a RequestedToCapacityRatio shape point
+// may carry; shape scores are scaled from [0, maxShapeScore] to node scores.
+const maxShapeScore = 10
+
+// usageScorer maps GPU usage on a node, counting the pod being
+// scheduled, to [0, MaxNodeScore] for the configured strategy. It returns
+// nil for Topology, which ranks nodes on topology alone.
+func usageScorer(s config.ScoringStrategy) func(requested, capacity int) int64 {
+	var score func(requested, capacity int64) int64
+	switch s.Type {
+	case config.MostAllocated:
+		score = func(requested, capacity int64) int64 {
+			return requested * framework.MaxNodeScore / capacity
+		}
+	case config.LeastAllocated:
+		score = func(requested, capacity int64) int64 {
+			return (capacity - requested) * framework.MaxNodeScore / capacity
+		}
+	case config.RequestedToCapacityRatio:
+		var shape helper.FunctionShape
+		if s.RequestedToCapacityRatio != nil {
+			for _, pt := range s.RequestedToCapacityRatio.Shape {
+				shape = append(shape, helper.FunctionShapePoint{
+					Utilization: int64(pt.Utilization),
+					Score:       int64(pt.Score) * framework.MaxNodeScore / maxShapeScore,
+				})
+			}
+		}
+		f := helper.BuildBrokenLinearFunction(shape)
+		score = func(requested, capacity int64) int64 {
+			return f(requested * 100 / capacity)
+		}
+	default:
+		return nil
+	}
+	return func(requested, capacity int) int64 {
+		if capacity <= 0 || requested > capacity {
+			return 0
+		}
+		return score(int64(requested), int64(capacity))
+	}
+}
+
+// wholeness scores the free devices left once ids are taken, from
+// MaxNodeScore when they form one buddy block down to 0 when they are all
+// split apart, so nodes whose pick fills a gap rank above nodes it splits.
+func wholeness(free []apiv1.Device, ids []int) int64 {
+	var left []apiv1.Device
+	for _, d := range free {
+		if !slices.Contains(ids, d.ID) {
+			left = append(left, d)
+		}
+	}
+	return framework.MaxNodeScore - int64(topo.Fragmentation(deviceInfos(left)))*framework.MaxNodeScore/100
+}
+
+// combineScores blends normalized topology scores with the fragmentation and,
+// when usage is scored, usage scores Score recorded per node, weighted by the
+// strategy.
+func combineScores(data *stateData, s config.ScoringStrategy, usage bool, scores framework.NodeScoreList) {
+	tw, aw, fw := int64(s.TopologyWeight), int64(s.AllocationWeight), int64(s.FragmentationWeight)
+	if !usage {
+		aw = 0
+	}
+	if tw+aw+fw == 0 {
+		return
+	}
+	for i := range scores {
+		pick := data.pick(scores[i].Name)
+		scores[i].Score = (tw*scores[i].Score + aw*pick.usage + fw*pick.wholeness) / (tw + aw + fw)
+	}
+}
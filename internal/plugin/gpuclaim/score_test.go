@@ -0,0 +1,151 @@
+package gpuclaim
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework"
+
+	apiv1 "github.com/ziwon/gpu-scheduler/api/v1"
+	"github.com/ziwon/gpu-scheduler/internal/plugin/gpuclaim/config"
+)
+
+func TestUsageScorer(t *testing.T) {
+	spread := &config.RequestedToCapacityRatioParam{
+		Shape: []config.UtilizationShapePoint{{Utilization: 0, Score: 10}, {Utilization: 100, Score: 0}},
+	}
+	tests := []struct {
+		name      string
+		strategy  config.ScoringStrategy
+		requested int
+		capacity  int
+		want      int64
+	}{
+		{name: "most allocated", strategy: config.ScoringStrategy{Type: config.MostAllocated}, requested: 3, capacity: 4, want: 75},
+		{name: "least allocated", strategy: config.ScoringStrategy{Type: config.LeastAllocated}, requested: 3, capacity: 4, want: 25},
+		{name: "ratio shape", strategy: config.ScoringStrategy{Type: config.RequestedToCapacityRatio, RequestedToCapacityRatio: spread}, requested: 1, capacity: 4, want: 75},
+		{name: "no capacity", strategy: config.ScoringStrategy{Type: config.MostAllocated}, requested: 1, capacity: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usageScorer(tt.strategy)(tt.requested, tt.capacity); got != tt.want {
+				t.Errorf("score(%d/%d) = %d, want %d", tt.requested, tt.capacity, got, tt.want)
+			}
+		})
+	}
+	if usageScorer(config.ScoringStrategy{Type: config.Topology}) != nil {
+		t.Error("Topology strategy should not score usage")
+	}
+}
+
+func TestScoreStrategies(t *testing.T) {
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}},
+	}
+	devs := []apiv1.Device{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		strategy config.ScoringStrategy
+		want     string
+	}{
+		{strategy: config.ScoringStrategy{Type: config.MostAllocated, TopologyWeight: 1, AllocationWeight: 1}, want: "busy"},
+		{strategy: config.ScoringStrategy{Type: config.LeastAllocated, TopologyWeight: 1, AllocationWeight: 1}, want: "idle"},
+		{strategy: config.ScoringStrategy{
+			Type:             config.RequestedToCapacityRatio,
+			TopologyWeight:   1,
+			AllocationWeight: 1,
+			RequestedToCapacityRatio: &config.RequestedToCapacityRatioParam{
+				Shape: []config.UtilizationShapePoint{{Utilization: 0, Score: 10}, {Utilization: 100, Score: 0}},
+			},
+		}, want: "idle"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy.Type), func(t *testing.T) {
+			p := newTestPlugin(t,
+				claim,
+				nodeStatus("busy", devs...),
+				nodeStatus("idle", devs...),
+				gpuLease("other", "busy", 0),
+				gpuLease("other", "busy", 1),
+			)
+			p.args.ScoringStrategy = tt.strategy
+			p.usageScore = usageScorer(tt.strategy)
+
+			scores := normalizedScores(t, p, claimPod("one"), "busy", "idle")
+			best := scores[0]
+			if scores[1].Score > best.Score {
+				best = scores[1]
+			}
+			if best.Name != tt.want || scores[0].Score == scores[1].Score {
+				t.Errorf("normalized scores = %v, want %s ranked first", scores, tt.want)
+			}
+		})
+	}
+}
+
+// Both nodes offer the same topology for one GPU. On "split" GPU 1 is taken,
+// so picking GPU 0 leaves GPUs 2 and 3 whole; on "whole" any pick splits the
+// four free GPUs. The fragmentation score ranks "split" first under every
+// strategy, and not at all when its weight is 0.
+func TestScoreFragmentation(t *testing.T) {
+	claim := &apiv1.GpuClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "default"},
+		Spec:       apiv1.GpuClaimSpec{Devices: apiv1.DeviceRequest{Count: 1}},
+	}
+	devs := []apiv1.Device{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		name     string
+		strategy config.ScoringStrategy
+		want     string
+	}{
+		{name: "topology", strategy: config.ScoringStrategy{Type: config.Topology, TopologyWeight: 1, FragmentationWeight: 1}, want: "split"},
+		{name: "least allocated", strategy: config.ScoringStrategy{Type: config.LeastAllocated, TopologyWeight: 1, AllocationWeight: 1, FragmentationWeight: 3}, want: "split"},
+		{name: "no fragmentation weight", strategy: config.ScoringStrategy{Type: config.Topology, TopologyWeight: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlugin(t,
+				claim,
+				nodeStatus("split", devs...),
+				nodeStatus("whole", devs...),
+				gpuLease("other", "split", 1),
+			)
+			p.args.ScoringStrategy = tt.strategy
+			p.usageScore = usageScorer(tt.strategy)
+
+			scores := normalizedScores(t, p, claimPod("one"), "split", "whole")
+			switch {
+			case tt.want == "" && scores[0].Score != scores[1].Score:
+				t.Errorf("normalized scores = %v, want a tie", scores)
+			case tt.want != "" && scores[0].Score <= scores[1].Score:
+				t.Errorf("normalized scores = %v, want %s ranked first", scores, tt.want)
+			}
+		})
+	}
+}
+
+// normalizedScores runs PreFilter, then Score on each node and NormalizeScore.
+func normalizedScores(t *testing.T, p *Plugin, pod *corev1.Pod, nodes ...string) framework.NodeScoreList {
+	t.Helper()
+	ctx := context.Background()
+	state := framework.NewCycleState()
+	if _, st := p.PreFilter(ctx, state, pod); !st.IsSuccess() {
+		t.Fatalf("PreFilter: %v", st)
+	}
+	var scores framework.NodeScoreList
+	for _, name := range nodes {
+		s, st := p.Score(ctx, state, pod, nodeInfo(name))
+		if !st.IsSuccess() {
+			t.Fatalf("Score(%s): %v", name, st)
+		}
+		scores = append(scores, framework.NodeScore{Name: name, Score: s})
+	}
+	if st := p.NormalizeScore(ctx, state, pod, scores); !st.IsSuccess() {
+		t.Fatalf("NormalizeScore: %v", st)
+	}
+	return scores
+}
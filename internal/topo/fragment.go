@@ -0,0 +1,136 @@
+package topo
+
+import "sort"
+
+// freeBlocks splits free devices into maximal buddy blocks: ID ranges of
+// power-of-two length, aligned to that length, whose devices share an island.
+// Block sizes are returned largest first.
+func freeBlocks(devs []DeviceInfo) []int {
+	island := make(map[int]string, len(devs))
+	for _, d := range devs {
+		island[d.ID] = d.Island
+	}
+	order := ids(devs)
+	sort.Ints(order)
+
+	var blocks []int
+	covered := map[int]bool{}
+	for _, start := range order {
+		if covered[start] {
+			continue
+		}
+		size := 1
+		for start%(size*2) == 0 && sameIslandRange(island, start, size*2) {
+			size *= 2
+		}
+		for id := start; id < start+size; id++ {
+			covered[id] = true
+		}
+		blocks = append(blocks, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(blocks)))
+	return blocks
+}
+
+// sameIslandRange reports whether IDs [start, start+size) are all free and
+// share start's island.
+func sameIslandRange(island map[int]string, start, size int) bool {
+	for id := start; id < start+size; id++ {
+		if is, ok := island[id]; !ok || is != island[start] {
+			return false
+		}
+	}
+	return true
+}
+
+// Fragmentation scores how scattered a node's free devices are, from 0 when
+// they form one buddy block to 100 when none is larger than a single device.
+// It is the share of free devices outside the largest block. The scheduler
+// scores every node by the fragmentation its pick leaves behind.
+func Fragmentation(free []DeviceInfo) int {
+	return fragmentationOf(freeBlocks(free))
+}
+
+// pickBuddy takes the contiguous same-island run whose removal leaves the
+// largest buddy blocks free, so small claims fill already split blocks and
+// whole islands stay available for large ones. Without such a run it falls
+// back to the contiguous policy. The score adds the remaining free devices'
+// lack of fragmentation to the contiguous score, so nodes whose pick keeps
+// them whole rank higher.
+func pickBuddy(devs []DeviceInfo, count int) (int, []int) {
+	var (
+		best       []int
+		bestBlocks []int
+		bestBW     int
+	)
+	for i := 0; i+count <= len(devs); i++ {
+		run := devs[i : i+count]
+		if !contiguousRun(run) {
+			continue
+		}
+		blocks := freeBlocks(without(devs, i, count))
+		if best == nil || largerBlocks(blocks, bestBlocks) {
+			best, bestBlocks, bestBW = ids(run), blocks, run[0].Bandwidth
+		}
+	}
+	if best == nil {
+		score, pick := pickContiguous(devs, count)
+		return score + 100 - Fragmentation(remaining(devs, pick)), pick
+	}
+	return 1000 + bestBW + 100 - fragmentationOf(bestBlocks), best
+}
+
+// contiguousRun reports whether the devices have consecutive IDs in one island.
+func contiguousRun(run []DeviceInfo) bool {
+	for k := 1; k < len(run); k++ {
+		if run[k].ID != run[0].ID+k || run[k].Island != run[0].Island {
+			return false
+		}
+	}
+	return true
+}
+
+// largerBlocks compares block sizes largest first and prefers the list that
+// keeps a larger block at the first difference.
+func largerBlocks(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fragmentationOf is Fragmentation for precomputed blocks.
+func fragmentationOf(blocks []int) int {
+	free := 0
+	for _, b := range blocks {
+		free += b
+	}
+	if free <= 1 {
+		return 0
+	}
+	return 100 * (free - blocks[0]) / (free - 1)
+}
+
+// without returns devs minus the count devices starting at index i.
+func without(devs []DeviceInfo, i, count int) []DeviceInfo {
+	out := make([]DeviceInfo, 0, len(devs)-count)
+	out = append(out, devs[:i]...)
+	return append(out, devs[i+count:]...)
+}
+
+// remaining returns the devices not in pick.
+func remaining(devs []DeviceInfo, pick []int) []DeviceInfo {
+	taken := make(map[int]bool, len(pick))
+	for _, id := range pick {
+		taken[id] = true
+	}
+	var out []DeviceInfo
+	for _, d := range devs {
+		if !taken[d.ID] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
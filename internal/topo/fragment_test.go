@@ -0,0 +1,101 @@
+package topo
+
+import (
+	"reflect"
+	"testing"
+)
+
+// island returns free devices with the given IDs in one NVLink island.
+func island(name string, ids ...int) []DeviceInfo {
+	out := make([]DeviceInfo, len(ids))
+	for i, id := range ids {
+		out[i] = DeviceInfo{ID: id, Island: name}
+	}
+	return out
+}
+
+func TestPickBuddy(t *testing.T) {
+	tests := []struct {
+		name   string
+		devs   []DeviceInfo
+		count  int
+		want   []int
+		wantOK bool
+	}{
+		{
+			name:   "single GPU fills split block",
+			devs:   island("nv0", 0, 1, 2, 3, 4, 5, 6),
+			count:  1,
+			want:   []int{6},
+			wantOK: true,
+		},
+		{
+			name:   "pair keeps aligned quad free",
+			devs:   island("nv0", 0, 1, 2, 3, 4, 5),
+			count:  2,
+			want:   []int{4, 5},
+			wantOK: true,
+		},
+		{
+			name:   "blocks stop at island boundaries",
+			devs:   append(island("nv0", 0, 1, 2, 3), island("nv1", 5, 6, 7)...),
+			count:  1,
+			want:   []int{5},
+			wantOK: true,
+		},
+		{
+			name:   "no run falls back to contiguous",
+			devs:   island("nv0", 0, 2, 5),
+			count:  2,
+			want:   []int{0, 2},
+			wantOK: true,
+		},
+		{
+			name:   "not enough devices",
+			devs:   island("nv0", 0),
+			count:  2,
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, ok := Pick(tt.devs, Request{Count: tt.count, Policy: PolicyBuddy})
+			if ok != tt.wantOK {
+				t.Fatalf("Pick ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pick = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickBuddyPrefersFragmentedNode(t *testing.T) {
+	req := Request{Count: 1, Policy: PolicyBuddy}
+	whole, _, _ := Pick(island("nv0", 0, 1, 2, 3, 4, 5, 6, 7), req)
+	split, _, _ := Pick(island("nv0", 0, 1, 2, 3, 4, 5, 6), req)
+	if split <= whole {
+		t.Errorf("score on split node = %d, want above whole node's %d", split, whole)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	tests := []struct {
+		name string
+		devs []DeviceInfo
+		want int
+	}{
+		{name: "none free", want: 0},
+		{name: "one block", devs: island("nv0", 0, 1, 2, 3, 4, 5, 6, 7), want: 0},
+		{name: "split block", devs: island("nv0", 0, 1, 2, 3, 4, 5, 6), want: 50},
+		{name: "only singles", devs: island("nv0", 0, 2, 4, 6), want: 100},
+		{name: "islands split blocks", devs: append(island("nv0", 0, 1), island("nv1", 2, 3)...), want: 66},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fragmentation(tt.devs); got != tt.want {
+				t.Errorf("Fragmentation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
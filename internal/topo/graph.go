@@ -0,0 +1,120 @@
+package topo
+
+import "sort"
+
+// LinkType is the kind of interconnect between two devices, best first.
+type LinkType string
+
+const (
+	// LinkNVSwitch connects devices through an NVSwitch fabric.
+	LinkNVSwitch LinkType = "NVSwitch"
+	// LinkNVLink is a direct NVLink bridge.
+	LinkNVLink LinkType = "NVLink"
+	// LinkPCIeSwitch traverses one or more PCIe switches, not the host bridge.
+	LinkPCIeSwitch LinkType = "PCIeSwitch"
+	// LinkSocket traverses the host bridge of one CPU socket.
+	LinkSocket LinkType = "Socket"
+	// LinkSystem crosses the interconnect between CPU sockets, or is unknown.
+	LinkSystem LinkType = "System"
+)
+
+// defaultBandwidth is the GB/s assumed for a link reported without one.
+var defaultBandwidth = map[LinkType]int{
+	LinkNVSwitch:   900,
+	LinkNVLink:     300,
+	LinkPCIeSwitch: 32,
+	LinkSocket:     16,
+	LinkSystem:     8,
+}
+
+// Link is an edge of the device graph.
+type Link struct {
+	Type      LinkType
+	Bandwidth int // GB/s; 0 means the type's default
+}
+
+// Graph models a node as devices connected by weighted links. Devices that
+// are never connected are joined by LinkSystem.
+type Graph struct {
+	ids   []int
+	index map[int]int
+	links [][]Link
+}
+
+// NewGraph returns a graph of the given devices joined by LinkSystem.
+func NewGraph(ids []int) *Graph {
+	ids = append([]int(nil), ids...)
+	sort.Ints(ids)
+	g := &Graph{ids: ids, index: make(map[int]int, len(ids)), links: make([][]Link, len(ids))}
+	for i, id := range ids {
+		g.index[id] = i
+		g.links[i] = make([]Link, len(ids))
+		for j := range ids {
+			if i != j {
+				g.links[i][j] = Link{Type: LinkSystem, Bandwidth: defaultBandwidth[LinkSystem]}
+			}
+		}
+	}
+	return g
+}
+
+// Connect sets the link between two devices of the graph.
+func (g *Graph) Connect(a, b int, l Link) {
+	i, ok := g.index[a]
+	j, ok2 := g.index[b]
+	if !ok || !ok2 || i == j {
+		return
+	}
+	if l.Bandwidth <= 0 {
+		l.Bandwidth = defaultBandwidth[l.Type]
+	}
+	g.links[i][j], g.links[j][i] = l, l
+}
+
+// Link returns the link between two devices; ok is false if either is not in
+// the graph or they are the same device.
+func (g *Graph) Link(a, b int) (Link, bool) {
+	i, ok := g.index[a]
+	j, ok2 := g.index[b]
+	if !ok || !ok2 || i == j {
+		return Link{}, false
+	}
+	return g.links[i][j], true
+}
+
+// MinBandwidth returns the slowest link among the devices with the given ids,
+// or 0 when fewer than two of them are in the graph.
+func (g *Graph) MinBandwidth(ids []int) int {
+	low := -1
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			if l, ok := g.Link(a, b); ok && (low < 0 || l.Bandwidth < low) {
+				low = l.Bandwidth
+			}
+		}
+	}
+	return max(low, 0)
+}
+
+// nvlinkOnly returns a copy of g keeping the NVLink and NVSwitch links of at
+// least minBandwidth GB/s; every other pair is left without bandwidth.
+func (g *Graph) nvlinkOnly(minBandwidth int) *Graph {
+	out := &Graph{ids: g.ids, index: g.index, links: make([][]Link, len(g.links))}
+	for i, row := range g.links {
+		out.links[i] = make([]Link, len(row))
+		for j, l := range row {
+			if (l.Type == LinkNVLink || l.Type == LinkNVSwitch) && l.Bandwidth >= minBandwidth {
+				out.links[i][j] = l
+			} else {
+				out.links[i][j] = Link{Type: l.Type}
+			}
+		}
+	}
+	return out
+}
+
+// IDs returns the graph's device IDs in ascending order.
+func (g *Graph) IDs() []int { return append([]int(nil), g.ids...) }
+
+// bandwidth returns the pairwise bandwidth by graph index.
+func (g *Graph) bandwidth(i, j int) int { return g.links[i][j].Bandwidth }
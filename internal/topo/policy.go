@@ -0,0 +1,253 @@
+package topo
+
+import "sort"
+
+// Device allocation policies understood by Pick.
+const (
+	PolicyContiguous = "contiguous"
+	PolicySpread     = "spread"
+	PolicyPreferIDs  = "preferIds"
+	PolicyBuddy      = "buddy"
+)
+
+// Topology modes understood by Pick.
+const (
+	TopologyRequired  = "Required"
+	TopologyPreferred = "Preferred"
+	TopologyIgnore    = "Ignore"
+)
+
+// preferredBonus lifts picks that satisfy a Preferred topology above any pick
+// that does not, whatever the policy score.
+const preferredBonus = 10000
+
+// Request describes the devices a claim asks for.
+type Request struct {
+	Count     int
+	Policy    string // contiguous (default)|spread|preferIds|buddy
+	PreferIDs []int
+	// StrictPreferIDs rejects picks that do not consist solely of PreferIDs.
+	StrictPreferIDs bool
+	// Topology is Required|Preferred|Ignore; empty keeps island-aware policies
+	// without enforcing MinBandwidth.
+	Topology     string
+	MinBandwidth int // GB/s every picked device must reach within its island
+	// Graph holds the pairwise links reported for the node. When set,
+	// Required and Preferred topologies hold every picked pair to NVLink at
+	// MinBandwidth instead of islands and per-device bandwidth.
+	Graph *Graph
+}
+
+// Pick selects req.Count devices from the free set according to req.Policy.
+// The returned score is non-negative and only comparable between picks made
+// for the same request; ok is false when the request cannot be satisfied.
+func Pick(devs []DeviceInfo, req Request) (score int, pick []int, ok bool) {
+	if req.Count <= 0 || len(devs) < req.Count {
+		return 0, nil, false
+	}
+	devs = append([]DeviceInfo(nil), devs...)
+	sort.Slice(devs, func(i, j int) bool { return devs[i].ID < devs[j].ID })
+
+	switch req.Topology {
+	case TopologyIgnore:
+		for i := range devs {
+			devs[i] = DeviceInfo{ID: devs[i].ID}
+		}
+	case TopologyRequired:
+		return pickConnected(devs, req)
+	case TopologyPreferred:
+		if score, pick, ok := pickConnected(devs, req); ok {
+			return score + preferredBonus, pick, true
+		}
+	}
+	return pickPolicy(devs, req)
+}
+
+// pickConnected returns a pick whose devices are all joined by NVLink at
+// req.MinBandwidth, judged by the node's links when it reports them.
+func pickConnected(devs []DeviceInfo, req Request) (score int, pick []int, ok bool) {
+	if req.Graph == nil || req.Count == 1 {
+		return pickWithinIsland(devs, req)
+	}
+	return pickWithLinks(devs, req)
+}
+
+// pickWithLinks keeps the policy pick when every pair of it is joined by
+// NVLink or NVSwitch at req.MinBandwidth or more, and otherwise takes the
+// subset whose slowest link is fastest, if that one qualifies.
+func pickWithLinks(devs []DeviceInfo, req Request) (score int, pick []int, ok bool) {
+	g := req.Graph.nvlinkOnly(req.MinBandwidth)
+	if score, pick, ok := pickPolicy(devs, req); ok && g.MinBandwidth(pick) > 0 {
+		return score, pick, true
+	}
+	sub, ok := g.BestSubset(ids(devs), req.Count, MaxMinBandwidth)
+	if !ok || sub.MinBandwidth == 0 {
+		return 0, nil, false
+	}
+	return 500 + sub.MinBandwidth, sub.IDs, true
+}
+
+// pickWithinIsland returns the best policy pick confined to a single island
+// whose devices all reach req.MinBandwidth.
+func pickWithinIsland(devs []DeviceInfo, req Request) (score int, pick []int, ok bool) {
+	var eligible []DeviceInfo
+	for _, d := range devs {
+		if d.Bandwidth >= req.MinBandwidth {
+			eligible = append(eligible, d)
+		}
+	}
+	bestScore := -1
+	for _, island := range groupBy(eligible, func(d DeviceInfo) string { return d.Island }) {
+		if len(island) < req.Count {
+			continue
+		}
+		if s, p, ok := pickPolicy(island, req); ok && s > bestScore {
+			bestScore, pick = s, p
+		}
+	}
+	return max(bestScore, 0), pick, pick != nil
+}
+
+// MinBandwidth returns the lowest bandwidth among the devices with the given ids.
+func MinBandwidth(devs []DeviceInfo, pick []int) int {
+	want := make(map[int]bool, len(pick))
+	for _, id := range pick {
+		want[id] = true
+	}
+	var picked []DeviceInfo
+	for _, d := range devs {
+		if want[d.ID] {
+			picked = append(picked, d)
+		}
+	}
+	if len(picked) == 0 {
+		return 0
+	}
+	return minBandwidth(picked)
+}
+
+func pickPolicy(devs []DeviceInfo, req Request) (score int, pick []int, ok bool) {
+	switch req.Policy {
+	case PolicySpread:
+		score, pick = pickSpread(devs, req.Count)
+	case PolicyPreferIDs:
+		score, pick = pickPreferred(devs, req.Count, req.PreferIDs, req.StrictPreferIDs)
+	case PolicyBuddy:
+		score, pick = pickBuddy(devs, req.Count)
+	default:
+		score, pick = pickContiguous(devs, req.Count)
+	}
+	return score, pick, pick != nil
+}
+
+// pickContiguous prefers, in order: a contiguous run inside one island, any
+// devices inside one island, and finally the pick spanning the fewest islands.
+func pickContiguous(devs []DeviceInfo, count int) (int, []int) {
+	if score, pick := ScoreContiguousSameIsland(devs, count); pick != nil {
+		return score, pick
+	}
+
+	groups := groupBy(devs, func(d DeviceInfo) string { return d.Island })
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i]) > len(groups[j]) })
+	if len(groups[0]) >= count {
+		// Smallest island that still fits keeps larger islands whole.
+		best := groups[0]
+		for _, g := range groups[1:] {
+			if len(g) >= count {
+				best = g
+			}
+		}
+		return 500 + minBandwidth(best[:count]), ids(best[:count])
+	}
+
+	var pick []DeviceInfo
+	islands := 0
+	for _, g := range groups {
+		if len(pick) >= count {
+			break
+		}
+		islands++
+		pick = append(pick, g[:min(len(g), count-len(pick))]...)
+	}
+	return max(100-islands, 1), ids(pick)
+}
+
+// pickSpread distributes the request round-robin across islands and, within
+// an island, across PCIe roots, so each device gets its own links.
+func pickSpread(devs []DeviceInfo, count int) (int, []int) {
+	groups := groupBy(devs, func(d DeviceInfo) string { return d.Island + "/" + d.PCIeRoot })
+	var pick []DeviceInfo
+	for round := 0; len(pick) < count; round++ {
+		for _, g := range groups {
+			if round < len(g) && len(pick) < count {
+				pick = append(pick, g[round])
+			}
+		}
+	}
+
+	islands := map[string]struct{}{}
+	roots := map[string]struct{}{}
+	for _, d := range pick {
+		islands[d.Island] = struct{}{}
+		roots[d.Island+"/"+d.PCIeRoot] = struct{}{}
+	}
+	return 100*len(islands) + 10*len(roots), ids(pick)
+}
+
+// pickPreferred takes free PreferIDs in the order given and, unless strict,
+// fills the remainder with the contiguous policy.
+func pickPreferred(devs []DeviceInfo, count int, prefer []int, strict bool) (int, []int) {
+	byID := make(map[int]DeviceInfo, len(devs))
+	for _, d := range devs {
+		byID[d.ID] = d
+	}
+
+	var pick []int
+	for _, id := range prefer {
+		if _, ok := byID[id]; ok && len(pick) < count {
+			pick = append(pick, id)
+			delete(byID, id)
+		}
+	}
+	hits := len(pick)
+	if hits == count {
+		return 100 * hits, pick
+	}
+	if strict {
+		return 0, nil
+	}
+
+	rest := make([]DeviceInfo, 0, len(byID))
+	for _, d := range devs {
+		if _, ok := byID[d.ID]; ok {
+			rest = append(rest, d)
+		}
+	}
+	_, fill := pickContiguous(rest, count-hits)
+	return 100 * hits, append(pick, fill...)
+}
+
+// groupBy partitions devices by key, preserving first-seen order.
+func groupBy(devs []DeviceInfo, key func(DeviceInfo) string) [][]DeviceInfo {
+	index := map[string]int{}
+	var out [][]DeviceInfo
+	for _, d := range devs {
+		k := key(d)
+		i, ok := index[k]
+		if !ok {
+			i = len(out)
+			index[k] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], d)
+	}
+	return out
+}
+
+func minBandwidth(devs []DeviceInfo) int {
+	low := devs[0].Bandwidth
+	for _, d := range devs[1:] {
+		low = min(low, d.Bandwidth)
+	}
+	return low
+}
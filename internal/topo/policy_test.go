@@ -0,0 +1,235 @@
+package topo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	// Two NVLink islands of four GPUs, each split over two PCIe roots.
+	dgx := []DeviceInfo{
+		{ID: 0, Island: "nv0", PCIeRoot: "r0", Bandwidth: 600},
+		{ID: 1, Island: "nv0", PCIeRoot: "r0", Bandwidth: 600},
+		{ID: 2, Island: "nv0", PCIeRoot: "r1", Bandwidth: 600},
+		{ID: 3, Island: "nv0", PCIeRoot: "r1", Bandwidth: 600},
+		{ID: 4, Island: "nv1", PCIeRoot: "r2", Bandwidth: 600},
+		{ID: 5, Island: "nv1", PCIeRoot: "r2", Bandwidth: 600},
+		{ID: 6, Island: "nv1", PCIeRoot: "r3", Bandwidth: 600},
+		{ID: 7, Island: "nv1", PCIeRoot: "r3", Bandwidth: 600},
+	}
+	// Free GPUs left with holes so no contiguous run exists.
+	holes := []DeviceInfo{
+		{ID: 0, Island: "nv0"},
+		{ID: 2, Island: "nv0"},
+		{ID: 5, Island: "nv1"},
+	}
+
+	tests := []struct {
+		name   string
+		devs   []DeviceInfo
+		req    Request
+		want   []int
+		wantOK bool
+	}{
+		{
+			name:   "contiguous run",
+			devs:   dgx,
+			req:    Request{Count: 4},
+			want:   []int{0, 1, 2, 3},
+			wantOK: true,
+		},
+		{
+			name:   "contiguous falls back to one island",
+			devs:   holes,
+			req:    Request{Count: 2, Policy: PolicyContiguous},
+			want:   []int{0, 2},
+			wantOK: true,
+		},
+		{
+			name:   "contiguous spans fewest islands",
+			devs:   holes,
+			req:    Request{Count: 3, Policy: PolicyContiguous},
+			want:   []int{0, 2, 5},
+			wantOK: true,
+		},
+		{
+			name:   "spread across islands then roots",
+			devs:   dgx,
+			req:    Request{Count: 4, Policy: PolicySpread},
+			want:   []int{0, 2, 4, 6},
+			wantOK: true,
+		},
+		{
+			name:   "preferIds best effort fills remainder",
+			devs:   holes,
+			req:    Request{Count: 2, Policy: PolicyPreferIDs, PreferIDs: []int{5, 1}},
+			want:   []int{5, 0},
+			wantOK: true,
+		},
+		{
+			name:   "preferIds strict",
+			devs:   holes,
+			req:    Request{Count: 2, Policy: PolicyPreferIDs, PreferIDs: []int{5, 1}, StrictPreferIDs: true},
+			wantOK: false,
+		},
+		{
+			name:   "not enough devices",
+			devs:   holes,
+			req:    Request{Count: 4},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, ok := Pick(tt.devs, tt.req)
+			if ok != tt.wantOK {
+				t.Fatalf("Pick ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pick = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickTopology(t *testing.T) {
+	// Island nv0 is fast but has only two free GPUs; nv1 is slow but larger.
+	devs := []DeviceInfo{
+		{ID: 0, Island: "nv0", Bandwidth: 600},
+		{ID: 1, Island: "nv0", Bandwidth: 600},
+		{ID: 4, Island: "nv1", Bandwidth: 64},
+		{ID: 5, Island: "nv1", Bandwidth: 64},
+		{ID: 6, Island: "nv1", Bandwidth: 64},
+	}
+
+	tests := []struct {
+		name   string
+		req    Request
+		want   []int
+		wantOK bool
+	}{
+		{
+			name:   "required fits fast island",
+			req:    Request{Count: 2, Topology: TopologyRequired, MinBandwidth: 300},
+			want:   []int{0, 1},
+			wantOK: true,
+		},
+		{
+			name:   "required rejects slow island",
+			req:    Request{Count: 3, Topology: TopologyRequired, MinBandwidth: 300},
+			wantOK: false,
+		},
+		{
+			name:   "preferred falls back across islands",
+			req:    Request{Count: 3, Topology: TopologyPreferred, MinBandwidth: 300},
+			want:   []int{4, 5, 6},
+			wantOK: true,
+		},
+		{
+			name:   "ignore treats devices as one pool",
+			req:    Request{Count: 4, Topology: TopologyIgnore, MinBandwidth: 300},
+			want:   []int{0, 1, 4, 5},
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, ok := Pick(devs, tt.req)
+			if ok != tt.wantOK {
+				t.Fatalf("Pick ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pick = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickTopologyLinks(t *testing.T) {
+	// Two NVSwitch trays of four GPUs joined by NVLink bridges between
+	// matching GPUs. Islands alone would see one island of eight.
+	all := make([]DeviceInfo, 8)
+	for i := range all {
+		all[i] = DeviceInfo{ID: i, Island: "nv0", Bandwidth: 900}
+	}
+	g := NewGraph(ids(all))
+	for a := 0; a < 8; a++ {
+		for b := a + 1; b < 8; b++ {
+			switch {
+			case a/4 == b/4:
+				g.Connect(a, b, Link{Type: LinkNVSwitch, Bandwidth: 900})
+			case b == a+4:
+				g.Connect(a, b, Link{Type: LinkNVLink, Bandwidth: 100})
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		free   []int
+		req    Request
+		want   []int
+		wantOK bool
+	}{
+		{
+			name:   "policy pick within a tray",
+			free:   []int{0, 1, 2, 3, 4, 5, 6, 7},
+			req:    Request{Count: 4, Topology: TopologyRequired, MinBandwidth: 600},
+			want:   []int{0, 1, 2, 3},
+			wantOK: true,
+		},
+		{
+			name:   "contiguous run spanning trays replaced",
+			free:   []int{2, 3, 4, 5, 6, 7},
+			req:    Request{Count: 4, Topology: TopologyRequired, MinBandwidth: 600},
+			want:   []int{4, 5, 6, 7},
+			wantOK: true,
+		},
+		{
+			name:   "bridge meets a low floor",
+			free:   []int{0, 4},
+			req:    Request{Count: 2, Topology: TopologyRequired},
+			want:   []int{0, 4},
+			wantOK: true,
+		},
+		{
+			name:   "bridge misses a high floor",
+			free:   []int{0, 4},
+			req:    Request{Count: 2, Topology: TopologyRequired, MinBandwidth: 300},
+			wantOK: false,
+		},
+		{
+			name:   "no NVLink between trays",
+			free:   []int{0, 1, 2, 3, 5},
+			req:    Request{Count: 5, Topology: TopologyRequired},
+			wantOK: false,
+		},
+		{
+			name:   "preferred falls back to the policy",
+			free:   []int{0, 1, 2, 3, 5},
+			req:    Request{Count: 5, Topology: TopologyPreferred},
+			want:   []int{0, 1, 2, 3, 5},
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var devs []DeviceInfo
+			for _, id := range tt.free {
+				devs = append(devs, all[id])
+			}
+			tt.req.Graph = g
+			_, got, ok := Pick(devs, tt.req)
+			if ok != tt.wantOK {
+				t.Fatalf("Pick ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pick = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := g.MinBandwidth([]int{0, 1, 4}); got != 8 {
+		t.Errorf("MinBandwidth(0,1,4) = %d, want the System default 8", got)
+	}
+}
@@ -0,0 +1,257 @@
+package topo
+
+import "sort"
+
+// Objective is what BestSubset maximizes.
+type Objective string
+
+const (
+	// MaxMinBandwidth maximizes the slowest pairwise link of the subset, then
+	// the aggregate bandwidth.
+	MaxMinBandwidth Objective = "MinBandwidth"
+	// MaxTotalBandwidth maximizes the sum of pairwise link bandwidths.
+	MaxTotalBandwidth Objective = "TotalBandwidth"
+)
+
+// searchBudget caps the subsets BestSubset expands per search; past it the
+// best subset found so far is returned.
+const searchBudget = 1 << 16
+
+// Subset is a device set chosen by BestSubset.
+type Subset struct {
+	IDs            []int
+	MinBandwidth   int // slowest pairwise link; 0 for a single device
+	TotalBandwidth int // sum of pairwise links
+}
+
+// BestSubset selects k of the free devices maximizing obj. The search is a
+// branch and bound over devices ordered by connectivity: a branch is cut when
+// even its best completion cannot beat the incumbent, interchangeable devices
+// (identical links to every other device) are tried once per level, and
+// MaxMinBandwidth memoizes which bandwidth floors admit a subset while it
+// bisects for the highest one.
+func (g *Graph) BestSubset(free []int, k int, obj Objective) (Subset, bool) {
+	var cand []int
+	for _, id := range free {
+		if i, ok := g.index[id]; ok {
+			cand = append(cand, i)
+		}
+	}
+	if k <= 0 || len(cand) < k {
+		return Subset{}, false
+	}
+
+	s := newSubsetSearch(g, cand, k)
+	if obj != MaxMinBandwidth {
+		return s.run(0)
+	}
+
+	// Floors are the distinct link bandwidths; whether a floor admits a
+	// subset is monotone, so bisect for the highest that does.
+	floors := s.floors()
+	memo := map[int]Subset{}
+	feasible := func(floor int) bool {
+		if _, ok := memo[floor]; ok {
+			return true
+		}
+		sub, ok := s.run(floor)
+		if ok {
+			memo[floor] = sub
+		}
+		return ok
+	}
+	lo, hi := 0, len(floors)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if feasible(floors[mid]) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if feasible(floors[lo]) {
+		return memo[floors[lo]], true
+	}
+	return s.run(0)
+}
+
+// subsetSearch holds the state of one branch and bound over graph indices.
+type subsetSearch struct {
+	g    *Graph
+	cand []int // graph indices, best connected first
+	// class numbers interchangeable candidates alike.
+	class []int
+	// maxEdge is each candidate's fastest link to another candidate.
+	maxEdge []int
+	k       int
+
+	floor    int
+	steps    int
+	chosen   []int // positions in cand
+	toChosen []int // per position, bandwidth summed over chosen
+	best     []int
+	bestSum  int
+}
+
+func newSubsetSearch(g *Graph, cand []int, k int) *subsetSearch {
+	degree := make(map[int]int, len(cand))
+	for _, i := range cand {
+		for _, j := range cand {
+			if i != j {
+				degree[i] += g.bandwidth(i, j)
+			}
+		}
+	}
+	sort.Slice(cand, func(a, b int) bool {
+		if degree[cand[a]] != degree[cand[b]] {
+			return degree[cand[a]] > degree[cand[b]]
+		}
+		return cand[a] < cand[b]
+	})
+
+	s := &subsetSearch{g: g, cand: cand, k: k, class: make([]int, len(cand)), maxEdge: make([]int, len(cand))}
+	for p, i := range cand {
+		s.class[p] = p
+		for q, j := range cand[:p] {
+			if s.class[q] == q && interchangeable(g, cand, i, j) {
+				s.class[p] = q
+				break
+			}
+		}
+		for _, j := range cand {
+			if i != j {
+				s.maxEdge[p] = max(s.maxEdge[p], g.bandwidth(i, j))
+			}
+		}
+	}
+	return s
+}
+
+// interchangeable reports whether devices i and j link identically to every
+// other candidate, so swapping them never changes a subset's bandwidth.
+func interchangeable(g *Graph, cand []int, i, j int) bool {
+	for _, x := range cand {
+		if x != i && x != j && g.bandwidth(i, x) != g.bandwidth(j, x) {
+			return false
+		}
+	}
+	return true
+}
+
+// floors returns the distinct candidate link bandwidths in ascending order.
+func (s *subsetSearch) floors() []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, i := range s.cand {
+		for _, j := range s.cand {
+			if bw := s.g.bandwidth(i, j); i != j && !seen[bw] {
+				seen[bw] = true
+				out = append(out, bw)
+			}
+		}
+	}
+	if len(out) == 0 {
+		out = []int{0}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// run searches for the k-subset with the highest aggregate bandwidth whose
+// pairwise links all reach floor.
+func (s *subsetSearch) run(floor int) (Subset, bool) {
+	s.floor, s.steps, s.best, s.bestSum = floor, 0, nil, 0
+	s.chosen = s.chosen[:0]
+	s.toChosen = make([]int, len(s.cand))
+	s.dfs(0, 0)
+	if s.best == nil {
+		return Subset{}, false
+	}
+
+	sub := Subset{TotalBandwidth: s.bestSum}
+	for n, p := range s.best {
+		sub.IDs = append(sub.IDs, s.g.ids[s.cand[p]])
+		for _, q := range s.best[:n] {
+			bw := s.g.bandwidth(s.cand[p], s.cand[q])
+			if n == 1 || bw < sub.MinBandwidth {
+				sub.MinBandwidth = bw
+			}
+		}
+	}
+	sort.Ints(sub.IDs)
+	return sub, true
+}
+
+func (s *subsetSearch) dfs(start, sum int) {
+	if len(s.chosen) == s.k {
+		if s.best == nil || sum > s.bestSum {
+			s.best, s.bestSum = append([]int(nil), s.chosen...), sum
+		}
+		return
+	}
+	need := s.k - len(s.chosen)
+	if s.steps >= searchBudget || len(s.cand)-start < need {
+		return
+	}
+	s.steps++
+	if s.best != nil && s.bound(start, need, sum) <= s.bestSum {
+		return
+	}
+
+	tried := map[int]bool{}
+	for p := start; p <= len(s.cand)-need; p++ {
+		if tried[s.class[p]] || !s.compatible(p) {
+			continue
+		}
+		tried[s.class[p]] = true
+		s.add(p, 1)
+		s.dfs(p+1, sum+s.toChosen[p])
+		s.add(p, -1)
+	}
+}
+
+// compatible reports whether every link from position p to the chosen
+// devices reaches the floor.
+func (s *subsetSearch) compatible(p int) bool {
+	for _, q := range s.chosen {
+		if s.g.bandwidth(s.cand[p], s.cand[q]) < s.floor {
+			return false
+		}
+	}
+	return true
+}
+
+// add pushes (sign 1) or pops (sign -1) position p on the chosen set.
+func (s *subsetSearch) add(p, sign int) {
+	if sign > 0 {
+		s.chosen = append(s.chosen, p)
+	} else {
+		s.chosen = s.chosen[:len(s.chosen)-1]
+	}
+	for q := range s.cand {
+		if q != p {
+			s.toChosen[q] += sign * s.g.bandwidth(s.cand[p], s.cand[q])
+		}
+	}
+}
+
+// bound is an upper limit on the aggregate bandwidth of any completion: each
+// remaining slot adds its links to the chosen devices plus, at best, half its
+// fastest link to each of the other new devices.
+func (s *subsetSearch) bound(start, need, sum int) int {
+	gains := make([]int, 0, len(s.cand)-start)
+	for p := start; p < len(s.cand); p++ {
+		if s.compatible(p) {
+			gains = append(gains, 2*s.toChosen[p]+(need-1)*s.maxEdge[p])
+		}
+	}
+	if len(gains) < need {
+		return -1
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(gains)))
+	total := 0
+	for _, gain := range gains[:need] {
+		total += gain
+	}
+	return sum + total/2
+}
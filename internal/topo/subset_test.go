@@ -0,0 +1,137 @@
+package topo
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// hgx returns n devices in NVSwitch domains of eight, PCIe switch pairs
+// within a domain, and two CPU sockets of n/2 devices each.
+func hgx(n int) *Graph {
+	var all []int
+	for id := 0; id < n; id++ {
+		all = append(all, id)
+	}
+	g := NewGraph(all)
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			switch {
+			case a/8 == b/8:
+				g.Connect(a, b, Link{Type: LinkNVSwitch})
+			case a/2 == b/2:
+				g.Connect(a, b, Link{Type: LinkPCIeSwitch})
+			case a < n/2 == (b < n/2):
+				g.Connect(a, b, Link{Type: LinkSocket})
+			}
+		}
+	}
+	return g
+}
+
+func TestBestSubset(t *testing.T) {
+	g := hgx(16)
+	// Domain 0 has only 0-2 free; domain 1 has 9-14.
+	free := []int{0, 1, 2, 9, 10, 11, 12, 13, 14}
+
+	tests := []struct {
+		name   string
+		k      int
+		obj    Objective
+		want   []int
+		wantOK bool
+	}{
+		{name: "whole domain", k: 4, obj: MaxMinBandwidth, want: []int{9, 10, 11, 12}, wantOK: true},
+		{name: "aggregate stays in one domain", k: 3, obj: MaxTotalBandwidth, want: []int{9, 10, 11}, wantOK: true},
+		{name: "larger than any domain", k: 7, obj: MaxMinBandwidth, want: []int{0, 9, 10, 11, 12, 13, 14}, wantOK: true},
+		{name: "too many", k: 10, obj: MaxMinBandwidth, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := g.BestSubset(free, tt.k, tt.obj)
+			if ok != tt.wantOK {
+				t.Fatalf("BestSubset ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got.IDs, tt.want) {
+				t.Errorf("BestSubset = %v, want %v", got.IDs, tt.want)
+			}
+		})
+	}
+}
+
+func TestBestSubsetMatchesExhaustive(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	bandwidths := []int{8, 16, 32, 300, 600, 900}
+	for trial := 0; trial < 40; trial++ {
+		n := 4 + rng.Intn(7)
+		var all []int
+		for id := 0; id < n; id++ {
+			all = append(all, id)
+		}
+		g := NewGraph(all)
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				g.Connect(a, b, Link{Type: LinkNVLink, Bandwidth: bandwidths[rng.Intn(len(bandwidths))]})
+			}
+		}
+		k := 1 + rng.Intn(n)
+		wantMin, wantTotal := exhaustive(g, all, k)
+
+		got, ok := g.BestSubset(all, k, MaxMinBandwidth)
+		if !ok || got.MinBandwidth != wantMin.MinBandwidth || got.TotalBandwidth != wantMin.TotalBandwidth {
+			t.Errorf("trial %d: MaxMinBandwidth = %+v, want min %d total %d", trial, got, wantMin.MinBandwidth, wantMin.TotalBandwidth)
+		}
+		got, ok = g.BestSubset(all, k, MaxTotalBandwidth)
+		if !ok || got.TotalBandwidth != wantTotal.TotalBandwidth {
+			t.Errorf("trial %d: MaxTotalBandwidth = %+v, want total %d", trial, got, wantTotal.TotalBandwidth)
+		}
+	}
+}
+
+func TestBestSubsetLargeNode(t *testing.T) {
+	g := hgx(64)
+	free := g.IDs()[3:]
+	start := time.Now()
+	got, ok := g.BestSubset(free, 8, MaxMinBandwidth)
+	if !ok || got.MinBandwidth != defaultBandwidth[LinkNVSwitch] {
+		t.Fatalf("BestSubset = %+v, %v, want a whole NVSwitch domain", got, ok)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("BestSubset took %v on 64 devices", elapsed)
+	}
+}
+
+// exhaustive returns the best k-subsets by min-then-total and by total.
+func exhaustive(g *Graph, all []int, k int) (byMin, byTotal Subset) {
+	first := true
+	var walk func(start int, pick []int)
+	walk = func(start int, pick []int) {
+		if len(pick) == k {
+			sub := Subset{IDs: append([]int(nil), pick...)}
+			for i, a := range pick {
+				for _, b := range pick[:i] {
+					l, _ := g.Link(a, b)
+					if i == 1 || l.Bandwidth < sub.MinBandwidth {
+						sub.MinBandwidth = l.Bandwidth
+					}
+					sub.TotalBandwidth += l.Bandwidth
+				}
+			}
+			if first || sub.MinBandwidth > byMin.MinBandwidth ||
+				sub.MinBandwidth == byMin.MinBandwidth && sub.TotalBandwidth > byMin.TotalBandwidth {
+				byMin = sub
+			}
+			if first || sub.TotalBandwidth > byTotal.TotalBandwidth {
+				byTotal = sub
+			}
+			first = false
+			return
+		}
+		for i := start; i < len(all); i++ {
+			walk(i+1, append(pick, all[i]))
+		}
+	}
+	walk(0, nil)
+	return byMin, byTotal
+}
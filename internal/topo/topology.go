@@ -6,15 +6,10 @@ import "sort"
 type DeviceInfo struct {
 	ID        int
 	Island    string
+	PCIeRoot  string
 	Bandwidth int // GB/s to peers within the chosen set
 }
 
-// NodeInfo bundles devices for a node (placeholder for future logic).
-type NodeInfo struct {
-	Name    string
-	Devices []DeviceInfo
-}
-
 // ScoreContiguousSameIsland returns a score and contiguous pick that share the island.
 func ScoreContiguousSameIsland(devs []DeviceInfo, count int) (score int, pick []int) {
 	if len(devs) < count {
@@ -11,6 +11,8 @@ const (
 	AnnoClaim = "gpu.scheduling/claim"
 	// AnnoAllocated stores the resolved `node:ids` payload for webhook consumption.
 	AnnoAllocated = "gpu.scheduling/allocated"
+	// AnnoGangSize declares the gang size when the claim does not set gangSize.
+	AnnoGangSize = "gpu.scheduling/gang-size"
 )
 
 // SetAllocated annotates the pod with the resolved node and GPU ids.
@@ -24,6 +26,19 @@ func SetAllocated(p *corev1.Pod, node string, ids []int) {
 	p.Annotations = m
 }
 
+// GetAllocated returns the GPU ids recorded on the pod by SetAllocated.
+func GetAllocated(p *corev1.Pod) ([]int, bool) {
+	raw, ok := p.GetAnnotations()[AnnoAllocated]
+	if !ok {
+		return nil, false
+	}
+	var ids []int
+	if err := json.Unmarshal([]byte("["+raw+"]"), &ids); err != nil {
+		return nil, false
+	}
+	return ids, true
+}
+
 func trimList(b []byte) string {
 	if len(b) >= 2 && b[0] == '[' && b[len(b)-1] == ']' {
 		return string(b[1 : len(b)-1])